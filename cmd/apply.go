@@ -0,0 +1,69 @@
+/*
+Copyright © 2021 Morgan Hein <work@morganhe.in>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"context"
+	"time"
+
+	"github.com/morganhein/envy/pkg/io"
+	"github.com/morganhein/envy/pkg/manager"
+	"github.com/spf13/cobra"
+)
+
+var profile string
+
+// applyCmd represents the apply command
+var applyCmd = &cobra.Command{
+	Use:   "apply",
+	Short: "Run every task in the profile for this machine",
+	Long: `Run every task in the profile for this machine. The profile is selected by matching the hostname or facts
+declared in each [profile.<name>] block, falling back to the "default" profile, or it can be chosen with --profile.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		//TODO (@morgan): this should be replaced with a canceller that catches user ctrl+c keypresses
+		ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+		defer cancel()
+		sh, err := io.CreateShell()
+		cobra.CheckErr(err)
+		mgr := manager.New(io.NewFilesystem(), sh)
+		appConfig := manager.RunConfig{
+			RecipeLocation: cfgFile,
+			Operation:      manager.APPLY,
+			Profile:        profile,
+			Sudo:           sudo,
+			Verbose:        verbose,
+			DryRun:         dryRun,
+			Strict:         strict,
+			Offline:        offline,
+			Progress:       io.NewTerminal(),
+		}
+		err = mgr.Start(ctx, appConfig, "")
+		if err != nil {
+			cobra.CheckErr(err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(applyCmd)
+	applyCmd.Flags().StringVarP(&profile, "profile", "p", "", "apply the named profile, instead of detecting it")
+}
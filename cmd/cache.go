@@ -0,0 +1,93 @@
+/*
+Copyright © 2021 Morgan Hein <work@morganhe.in>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/morganhein/envy/pkg/io"
+	"github.com/spf13/cobra"
+)
+
+var (
+	pruneOlderThan time.Duration
+	pruneAll       bool
+)
+
+// cacheCmd represents the cache command
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Work with the download cache",
+}
+
+// cacheListCmd represents the cache list command
+var cacheListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the cached downloads, most recently used first",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		dir, err := io.DefaultCacheDir()
+		cobra.CheckErr(err)
+		entries, err := io.NewDownloadCache(dir).List()
+		cobra.CheckErr(err)
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "URL\tSIZE\tLAST USED")
+		for _, entry := range entries {
+			fmt.Fprintf(w, "%v\t%v\t%v\n", entry.URL, entry.Size, entry.LastUsed.Format(time.RFC3339))
+		}
+		cobra.CheckErr(w.Flush())
+	},
+}
+
+// cachePruneCmd represents the cache prune command
+var cachePruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Remove cached downloads that haven't been used recently",
+	Long: `Remove cached downloads that haven't been used recently. By default downloads that
+haven't been used in 30 days are removed, for example:
+
+envy cache prune --older-than 168h
+envy cache prune --all`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		dir, err := io.DefaultCacheDir()
+		cobra.CheckErr(err)
+		cutoff := time.Now().Add(-pruneOlderThan)
+		if pruneAll {
+			cutoff = time.Time{}
+		}
+		freed, err := io.NewDownloadCache(dir).Prune(cutoff)
+		cobra.CheckErr(err)
+		fmt.Printf("freed %v bytes\n", freed)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(cacheCmd)
+	cacheCmd.AddCommand(cacheListCmd)
+	cacheCmd.AddCommand(cachePruneCmd)
+	cachePruneCmd.Flags().DurationVar(&pruneOlderThan, "older-than", 30*24*time.Hour, "remove downloads that haven't been used for this long")
+	cachePruneCmd.Flags().BoolVar(&pruneAll, "all", false, "remove every cached download")
+}
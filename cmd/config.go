@@ -0,0 +1,65 @@
+/*
+Copyright © 2021 Morgan Hein <work@morganhe.in>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/morganhein/envy/pkg/io"
+	"github.com/morganhein/envy/pkg/manager"
+	"github.com/spf13/cobra"
+)
+
+// configCmd represents the config command
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Work with recipe files",
+}
+
+// convertCmd represents the config convert command
+var convertCmd = &cobra.Command{
+	Use:   "convert [input] [output]",
+	Short: "Convert a recipe between the TOML, YAML and JSON formats",
+	Long: `Convert a recipe between the TOML, YAML and JSON formats. The formats are
+determined by the file extensions of the input and output files, and an input file with
+any other extension is TOML, for example:
+
+envy config convert recipe.toml recipe.yaml`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		// the output would silently be TOML otherwise
+		if !manager.HasRecipeExtension(args[1]) {
+			cobra.CheckErr(fmt.Errorf("unable to determine the recipe format of `%v`, expected a .toml, .yaml, .yml or .json extension", args[1]))
+		}
+		r, err := manager.LoadRecipe(io.NewFilesystem(), args[0])
+		cobra.CheckErr(err)
+		out, err := manager.EncodeRecipe(manager.FormatFromPath(args[1]), *r)
+		cobra.CheckErr(err)
+		cobra.CheckErr(os.WriteFile(args[1], out, 0644))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(configCmd)
+	configCmd.AddCommand(convertCmd)
+}
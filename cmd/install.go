@@ -55,6 +55,9 @@ to quickly create a Cobra application.`,
 			Sudo:           sudo,
 			Verbose:        verbose,
 			DryRun:         dryRun,
+			Strict:         strict,
+			Offline:        offline,
+			Progress:       io.NewTerminal(),
 			ForceInstaller: "", //TODO (@morgan): add this to the cobra loading
 		}
 		err = mgr.Start(ctx, appConfig, args[0])
@@ -28,6 +28,8 @@ import (
 var (
 	dryRun  bool
 	verbose bool
+	strict  bool
+	offline bool
 	sudo    string
 	cfgFile string
 )
@@ -48,6 +50,8 @@ func init() {
 	rootCmd.PersistentFlags().BoolVarP(&dryRun, "dry-run", "d", false, "echo commands only")
 	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "print lots of information")
 	rootCmd.PersistentFlags().StringVarP(&sudo, "sudo", "s", "", "force enable/disable sudo")
+	rootCmd.PersistentFlags().BoolVar(&strict, "strict", false, "refuse to run commands that reference undefined variables")
+	rootCmd.PersistentFlags().BoolVar(&offline, "offline", false, "only use downloads from the download cache")
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.config/envy/config.toml)")
 
 	// Cobra also supports local flags, which will only run
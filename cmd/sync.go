@@ -23,6 +23,9 @@ package cmd
 
 import (
 	"fmt"
+
+	"github.com/morganhein/envy/pkg/io"
+	"github.com/morganhein/envy/pkg/manager"
 	"github.com/morganhein/envy/pkg/sync"
 
 	"github.com/spf13/cobra"
@@ -31,18 +34,63 @@ import (
 var (
 	sourcePath string
 	targetPath string
+	onConflict string
+	syncMode   string
+	linkDirs   bool
+	untracked  bool
+	listOnly   bool
 )
 
 // syncCmd represents the sync command
 var syncCmd = &cobra.Command{
 	Use:   "sync",
 	Short: "Sync from your repo of config files to their respective destinations",
-	Long:  `Long-er description`,
+	Long: `Sync from your repo of config files to their respective destinations. Files in the target that
+aren't linked from the source are conflicts, which are resolved by prompting, or all the same way with
+--on-conflict. Paths matching the ignores of the [sync] section of the recipe, or the .envyignore of the
+source, are never synced. For example:
+
+envy sync --source ~/.dotfiles --target ~ --on-conflict backup
+
+The [sync] section of the recipe sets the source, target, directories, ignores, mode and conflict policy,
+so that running envy sync without any flags doesn't ask anything.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		err := sync.Sync(sync.SyncConfig{
-			Source: sourcePath,
-			Target: targetPath,
+		recipe, err := manager.ResolveRecipe(io.NewFilesystem(), cfgFile)
+		cobra.CheckErr(err)
+		settings, err := manager.SyncSettings(*recipe)
+		cobra.CheckErr(err)
+		// the flags take precedence over the recipe
+		if len(sourcePath) > 0 {
+			settings.Source = sourcePath
+		}
+		if len(targetPath) > 0 {
+			settings.Target = targetPath
+		}
+		if len(onConflict) > 0 {
+			settings.OnConflict = onConflict
+		}
+		if len(syncMode) > 0 {
+			settings.Mode = syncMode
+		}
+		if cmd.Flags().Changed("link-dirs") {
+			settings.LinkDirs = &linkDirs
+		}
+		action, err := sync.ParseConflictAction(settings.OnConflict)
+		cobra.CheckErr(err)
+		mode, err := sync.ParseSyncMode(settings.Mode)
+		cobra.CheckErr(err)
+		err = sync.Sync(sync.SyncConfig{
+			Source: settings.Source,
+			Target: settings.Target,
 			DryRun: dryRun,
+			Dirs:   settings.Dirs,
+			// a recipe that sets the source doesn't ask which directories to sync
+			AllDirs:    len(recipe.Sync.Source) > 0,
+			Ignores:    settings.Ignores,
+			Mode:       mode,
+			DirLinks:   sync.DirLinks{All: settings.LinkDirs != nil && *settings.LinkDirs, Dirs: settings.DirLinks},
+			Untracked:  untracked,
+			OnConflict: action,
 		})
 		if err != nil {
 			fmt.Println(err)
@@ -50,8 +98,44 @@ var syncCmd = &cobra.Command{
 	},
 }
 
+// syncRestoreCmd represents the sync restore command
+var syncRestoreCmd = &cobra.Command{
+	Use:   "restore [timestamp]",
+	Short: "Put back the files a sync backed up",
+	Long: `Put back the files a sync backed up, replacing the links it made. Without a timestamp the latest
+backup is restored. Use --list to see the backups, for example:
+
+envy sync restore --list
+envy sync restore 20211003-123000`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if listOnly {
+			dir, err := sync.DefaultBackupDir()
+			cobra.CheckErr(err)
+			backups, err := sync.ListBackups(dir)
+			cobra.CheckErr(err)
+			for _, b := range backups {
+				fmt.Printf("%v  %d files from %v\n", b.Timestamp, len(b.Entries), b.Target)
+			}
+			return
+		}
+		timestamp := ""
+		if len(args) == 1 {
+			timestamp = args[0]
+		}
+		cobra.CheckErr(sync.Restore(timestamp, dryRun))
+	},
+}
+
 func init() {
 	rootCmd.AddCommand(syncCmd)
-	syncCmd.PersistentFlags().StringVarP(&sourcePath, "source", "s", "", "source [file]")
+	syncCmd.AddCommand(syncRestoreCmd)
+	syncRestoreCmd.Flags().BoolVar(&listOnly, "list", false, "list the backups instead of restoring one")
+	// -s is the global sudo flag
+	syncCmd.PersistentFlags().StringVar(&sourcePath, "source", "", "source [file]")
 	syncCmd.PersistentFlags().StringVarP(&targetPath, "target", "t", "", "target [file]")
+	syncCmd.PersistentFlags().StringVar(&onConflict, "on-conflict", "", "resolve every conflict without prompting: backup, adopt, ignore or skip")
+	syncCmd.PersistentFlags().StringVar(&syncMode, "mode", "", "link or copy the files of the source into the target (default link)")
+	syncCmd.Flags().BoolVar(&untracked, "untracked", false, "also find the files that are only in the target, and ask whether to adopt them")
+	syncCmd.Flags().BoolVar(&linkDirs, "link-dirs", false, "link each directory whole, instead of its files one by one")
 }
@@ -29,6 +29,8 @@ import (
 	"time"
 )
 
+var undo bool
+
 // taskCmd represents the task command
 var taskCmd = &cobra.Command{
 	Use:   "task [taskName]",
@@ -43,17 +45,24 @@ to quickly create a Cobra application.`,
 		if len(args) == 0 {
 			cobra.CheckErr("need task name")
 		}
-		ctx, cancel := context.WithTimeout(context.Background(), time.Minute*1)
+		ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
 		defer cancel()
 		sh, err := io.CreateShell()
 		cobra.CheckErr(err)
 		mgr := manager.New(io.NewFilesystem(), sh)
+		operation := manager.TASK
+		if undo {
+			operation = manager.UNDO
+		}
 		appConfig := manager.RunConfig{
 			RecipeLocation: cfgFile,
-			Operation:      manager.TASK,
+			Operation:      operation,
 			Sudo:           sudo,
 			Verbose:        verbose,
 			DryRun:         dryRun,
+			Strict:         strict,
+			Offline:        offline,
+			Progress:       io.NewTerminal(),
 			ForceInstaller: "", //TODO (@morgan): add this to the cobra loading
 		}
 		err = mgr.Start(ctx, appConfig, args[0])
@@ -65,4 +74,5 @@ to quickly create a Cobra application.`,
 
 func init() {
 	rootCmd.AddCommand(taskCmd)
+	taskCmd.Flags().BoolVarP(&undo, "undo", "u", false, "reverse the task by running its teardown")
 }
@@ -0,0 +1,70 @@
+/*
+Copyright © 2021 Morgan Hein <work@morganhe.in>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"context"
+	"time"
+
+	"github.com/morganhein/envy/pkg/io"
+	"github.com/morganhein/envy/pkg/manager"
+	"github.com/spf13/cobra"
+)
+
+// upgradeCmd represents the upgrade command
+var upgradeCmd = &cobra.Command{
+	Use:   "upgrade [pkg|task]",
+	Short: "Upgrade installed packages",
+	Long: `Upgrade installed packages. With no argument, everything is upgraded with every available installer.
+With a task name, only the packages the task references are upgraded. Otherwise the argument is a single package.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		name := ""
+		if len(args) == 1 {
+			name = args[0]
+		}
+		//TODO (@morgan): this should be replaced with a canceller that catches user ctrl+c keypresses
+		ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+		defer cancel()
+		sh, err := io.CreateShell()
+		cobra.CheckErr(err)
+		mgr := manager.New(io.NewFilesystem(), sh)
+		appConfig := manager.RunConfig{
+			RecipeLocation: cfgFile,
+			Operation:      manager.UPGRADE,
+			Sudo:           sudo,
+			Verbose:        verbose,
+			DryRun:         dryRun,
+			Strict:         strict,
+			Offline:        offline,
+			Progress:       io.NewTerminal(),
+		}
+		err = mgr.Start(ctx, appConfig, name)
+		if err != nil {
+			cobra.CheckErr(err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(upgradeCmd)
+}
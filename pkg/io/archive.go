@@ -0,0 +1,389 @@
+package io
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/ulikunitz/xz"
+	"golang.org/x/xerrors"
+)
+
+var (
+	gzipMagic  = []byte{0x1f, 0x8b}
+	xzMagic    = []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}
+	bzip2Magic = []byte("BZh")
+	zipMagic   = []byte("PK\x03\x04")
+	tarMagic   = []byte("ustar")
+)
+
+// tarMagicOffset is where the ustar magic is in the first tar header
+const tarMagicOffset = 257
+
+var (
+	errNotArchive = errors.New("not a supported archive")
+	errStopWalk   = errors.New("stop walking the archive")
+)
+
+// ExtractOptions control which entries of an archive are extracted
+type ExtractOptions struct {
+	StripComponents int      // the number of leading path components removed from every entry
+	Include         []string // globs matched against the stripped paths, everything is extracted if empty
+}
+
+// archiveEntry is a file, directory or link in an archive
+type archiveEntry struct {
+	name     string
+	mode     os.FileMode // includes the type bits
+	linkname string
+	hardlink bool
+	open     func() (io.ReadCloser, error)
+}
+
+// ExtractArchive extracts the tar.gz, tar.xz, tar.bz2, tar or zip archive into the `to` directory.
+// Archives are recognized by their contents. Entries that would end up outside of `to`, including
+// through links, are refused, and file modes are preserved.
+func ExtractArchive(archive, to string, opts ExtractOptions) error {
+	root, err := filepath.Abs(to)
+	if err != nil {
+		return xerrors.Errorf("error resolving the destination directory: %v", err)
+	}
+	if err = os.MkdirAll(root, 0755); err != nil {
+		return xerrors.Errorf("error creating the destination directory: %v", err)
+	}
+	err = walkArchive(archive, func(e archiveEntry) error {
+		if escapesRoot(e.name) {
+			return xerrors.Errorf("refusing to extract `%v`, it is outside of the destination", e.name)
+		}
+		name, ok := stripComponents(e.name, opts.StripComponents)
+		if !ok || !includeEntry(name, opts.Include) {
+			return nil
+		}
+		return extractEntry(root, name, e, opts)
+	})
+	if err == errNotArchive {
+		return xerrors.Errorf("`%v` is not a supported archive, expected tar.gz, tar.xz, tar.bz2, tar or zip", archive)
+	}
+	return err
+}
+
+// ExtractFile finds the file called `name` in the archive, and writes it to `to` as an executable.
+// Anything that isn't a supported archive is assumed to be the file itself.
+func ExtractFile(archive, name, to string) error {
+	found := false
+	err := walkArchive(archive, func(e archiveEntry) error {
+		if !e.mode.IsRegular() || e.hardlink || path.Base(e.name) != name {
+			return nil
+		}
+		rc, err := e.open()
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = rc.Close()
+		}()
+		if err = writeExecutable(rc, to); err != nil {
+			return err
+		}
+		found = true
+		return errStopWalk
+	})
+	if err == errNotArchive {
+		f, err := os.Open(archive)
+		if err != nil {
+			return xerrors.Errorf("error opening download: %v", err)
+		}
+		defer func() {
+			_ = f.Close()
+		}()
+		return writeExecutable(f, to)
+	}
+	if err != nil {
+		return err
+	}
+	if !found {
+		return xerrors.Errorf("`%v` was not found in the archive", name)
+	}
+	return nil
+}
+
+// walkArchive calls fn for every entry of the archive, until fn returns an error. It returns errNotArchive
+// if the file isn't an archive it understands.
+func walkArchive(archive string, fn func(e archiveEntry) error) error {
+	f, err := os.Open(archive)
+	if err != nil {
+		return xerrors.Errorf("error opening archive: %v", err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	br := bufio.NewReader(f)
+	magic, _ := br.Peek(tarMagicOffset + len(tarMagic))
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return xerrors.Errorf("error reading gzip archive: %v", err)
+		}
+		defer func() {
+			_ = gz.Close()
+		}()
+		err = walkTar(gz, fn)
+		return ignoreStop(err)
+	case bytes.HasPrefix(magic, xzMagic):
+		xr, err := xz.NewReader(br)
+		if err != nil {
+			return xerrors.Errorf("error reading xz archive: %v", err)
+		}
+		return ignoreStop(walkTar(xr, fn))
+	case bytes.HasPrefix(magic, bzip2Magic):
+		return ignoreStop(walkTar(bzip2.NewReader(br), fn))
+	case bytes.HasPrefix(magic, zipMagic):
+		return ignoreStop(walkZip(archive, fn))
+	case len(magic) > tarMagicOffset && bytes.HasPrefix(magic[tarMagicOffset:], tarMagic):
+		return ignoreStop(walkTar(br, fn))
+	}
+	return errNotArchive
+}
+
+func ignoreStop(err error) error {
+	if err == errStopWalk {
+		return nil
+	}
+	return err
+}
+
+func walkTar(r io.Reader, fn func(e archiveEntry) error) error {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return xerrors.Errorf("error reading tar archive: %v", err)
+		}
+		e := archiveEntry{
+			name:     hdr.Name,
+			mode:     hdr.FileInfo().Mode(),
+			linkname: hdr.Linkname,
+			hardlink: hdr.Typeflag == tar.TypeLink,
+			open: func() (io.ReadCloser, error) {
+				return io.NopCloser(tr), nil
+			},
+		}
+		if err = fn(e); err != nil {
+			return err
+		}
+	}
+}
+
+func walkZip(archive string, fn func(e archiveEntry) error) error {
+	zr, err := zip.OpenReader(archive)
+	if err != nil {
+		return xerrors.Errorf("error reading zip archive: %v", err)
+	}
+	defer func() {
+		_ = zr.Close()
+	}()
+	for _, f := range zr.File {
+		f := f
+		e := archiveEntry{
+			name: f.Name,
+			mode: f.Mode(),
+			open: f.Open,
+		}
+		if e.mode&os.ModeSymlink != 0 {
+			// zip stores the target of a symlink as its contents
+			rc, err := f.Open()
+			if err != nil {
+				return xerrors.Errorf("error reading `%v` from the archive: %v", f.Name, err)
+			}
+			target, err := io.ReadAll(rc)
+			_ = rc.Close()
+			if err != nil {
+				return xerrors.Errorf("error reading `%v` from the archive: %v", f.Name, err)
+			}
+			e.linkname = string(target)
+		}
+		if err = fn(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// escapesRoot is true if the archive path climbs out of the directory it is extracted into
+func escapesRoot(name string) bool {
+	for _, part := range strings.Split(strings.ReplaceAll(name, "\\", "/"), "/") {
+		if part == ".." {
+			return true
+		}
+	}
+	return false
+}
+
+// stripComponents removes the leading path components of an archive path, and is false if nothing is left
+func stripComponents(name string, n int) (string, bool) {
+	name = path.Clean("/" + strings.ReplaceAll(name, "\\", "/"))[1:]
+	if name == "" {
+		return "", false
+	}
+	parts := strings.Split(name, "/")
+	if len(parts) <= n {
+		return "", false
+	}
+	return path.Join(parts[n:]...), true
+}
+
+// includeEntry is true if there are no globs, or a glob matches the path or one of its parent directories
+func includeEntry(name string, include []string) bool {
+	if len(include) == 0 {
+		return true
+	}
+	parts := strings.Split(name, "/")
+	for _, glob := range include {
+		for i := len(parts); i > 0; i-- {
+			if ok, _ := path.Match(strings.Trim(glob, "/"), path.Join(parts[:i]...)); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func extractEntry(root, name string, e archiveEntry, opts ExtractOptions) error {
+	target := filepath.Join(root, filepath.FromSlash(name))
+	if !insideDir(root, target) {
+		return xerrors.Errorf("refusing to extract `%v`, it is outside of the destination", e.name)
+	}
+	if err := checkParents(root, target); err != nil {
+		return err
+	}
+	switch {
+	case e.mode.IsDir():
+		if err := os.MkdirAll(target, e.mode.Perm()|0700); err != nil {
+			return xerrors.Errorf("error creating `%v`: %v", target, err)
+		}
+		return os.Chmod(target, e.mode.Perm()|0700)
+	case e.hardlink:
+		linkname, ok := stripComponents(e.linkname, opts.StripComponents)
+		source := filepath.Join(root, filepath.FromSlash(linkname))
+		if !ok || !insideDir(root, source) {
+			return xerrors.Errorf("refusing to extract `%v`, it links outside of the destination", e.name)
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return xerrors.Errorf("error creating `%v`: %v", filepath.Dir(target), err)
+		}
+		_ = os.Remove(target)
+		return os.Link(source, target)
+	case e.mode&os.ModeSymlink != 0:
+		if filepath.IsAbs(e.linkname) || !insideDir(root, filepath.Join(filepath.Dir(target), filepath.FromSlash(e.linkname))) {
+			return xerrors.Errorf("refusing to extract `%v`, it links outside of the destination", e.name)
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return xerrors.Errorf("error creating `%v`: %v", filepath.Dir(target), err)
+		}
+		_ = os.Remove(target)
+		return os.Symlink(e.linkname, target)
+	case e.mode.IsRegular():
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return xerrors.Errorf("error creating `%v`: %v", filepath.Dir(target), err)
+		}
+		rc, err := e.open()
+		if err != nil {
+			return xerrors.Errorf("error reading `%v` from the archive: %v", e.name, err)
+		}
+		defer func() {
+			_ = rc.Close()
+		}()
+		perm := e.mode.Perm()
+		if perm == 0 {
+			perm = 0644
+		}
+		_ = os.Remove(target)
+		f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+		if err != nil {
+			return xerrors.Errorf("error creating `%v`: %v", target, err)
+		}
+		if _, err = io.Copy(f, rc); err != nil {
+			_ = f.Close()
+			return xerrors.Errorf("error writing `%v`: %v", target, err)
+		}
+		if err = f.Close(); err != nil {
+			return xerrors.Errorf("error writing `%v`: %v", target, err)
+		}
+		// the umask applies when creating the file, so set the mode again
+		return os.Chmod(target, perm)
+	}
+	// devices, fifos and the like are never needed for tools and dotfiles
+	return nil
+}
+
+// insideDir is true if target is root, or inside of it
+func insideDir(root, target string) bool {
+	rel, err := filepath.Rel(root, target)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// checkParents makes sure none of the directories between root and target are symlinks, so an
+// entry can't be written through a link extracted earlier
+func checkParents(root, target string) error {
+	rel, err := filepath.Rel(root, filepath.Dir(target))
+	if err != nil || rel == "." {
+		return err
+	}
+	dir := root
+	for _, part := range strings.Split(rel, string(filepath.Separator)) {
+		dir = filepath.Join(dir, part)
+		info, err := os.Lstat(dir)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			return xerrors.Errorf("refusing to extract `%v`, its parent `%v` is a symlink", target, dir)
+		}
+	}
+	return nil
+}
+
+// writeExecutable writes to a temporary file next to `to` and renames it into place, so a
+// failed write never leaves a partial executable behind
+func writeExecutable(r io.Reader, to string) error {
+	if err := os.MkdirAll(filepath.Dir(to), 0755); err != nil {
+		return xerrors.Errorf("error creating destination directory: %v", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(to), "."+filepath.Base(to)+".*")
+	if err != nil {
+		return xerrors.Errorf("error creating destination file: %v", err)
+	}
+	defer func() {
+		_ = os.Remove(tmp.Name())
+	}()
+	if _, err = io.Copy(tmp, r); err != nil {
+		_ = tmp.Close()
+		return xerrors.Errorf("error writing destination file: %v", err)
+	}
+	if err = tmp.Close(); err != nil {
+		return xerrors.Errorf("error writing destination file: %v", err)
+	}
+	if err = os.Chmod(tmp.Name(), 0755); err != nil {
+		return xerrors.Errorf("error making destination file executable: %v", err)
+	}
+	return os.Rename(tmp.Name(), to)
+}
@@ -0,0 +1,155 @@
+package io
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/ulikunitz/xz"
+)
+
+type testEntry struct {
+	name     string
+	body     string
+	mode     int64
+	typeflag byte
+	linkname string
+}
+
+func writeTar(t *testing.T, w *tar.Writer, entries []testEntry) {
+	for _, e := range entries {
+		typeflag := e.typeflag
+		if typeflag == 0 {
+			typeflag = tar.TypeReg
+		}
+		hdr := &tar.Header{Name: e.name, Mode: e.mode, Typeflag: typeflag, Linkname: e.linkname}
+		if typeflag == tar.TypeReg {
+			hdr.Size = int64(len(e.body))
+		}
+		require.NoError(t, w.WriteHeader(hdr))
+		_, err := w.Write([]byte(e.body))
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Close())
+}
+
+func tarGzArchive(t *testing.T, entries []testEntry) string {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	writeTar(t, tar.NewWriter(gz), entries)
+	require.NoError(t, gz.Close())
+	name := filepath.Join(t.TempDir(), "archive.tar.gz")
+	require.NoError(t, os.WriteFile(name, buf.Bytes(), 0644))
+	return name
+}
+
+func tarXzArchive(t *testing.T, entries []testEntry) string {
+	var buf bytes.Buffer
+	xw, err := xz.NewWriter(&buf)
+	require.NoError(t, err)
+	writeTar(t, tar.NewWriter(xw), entries)
+	require.NoError(t, xw.Close())
+	name := filepath.Join(t.TempDir(), "archive.tar.xz")
+	require.NoError(t, os.WriteFile(name, buf.Bytes(), 0644))
+	return name
+}
+
+func zipArchive(t *testing.T, entries []testEntry) string {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, e := range entries {
+		hdr := &zip.FileHeader{Name: e.name, Method: zip.Deflate}
+		hdr.SetMode(os.FileMode(e.mode))
+		w, err := zw.CreateHeader(hdr)
+		require.NoError(t, err)
+		_, err = w.Write([]byte(e.body))
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+	name := filepath.Join(t.TempDir(), "archive.zip")
+	require.NoError(t, os.WriteFile(name, buf.Bytes(), 0644))
+	return name
+}
+
+func TestExtractArchive(t *testing.T) {
+	entries := []testEntry{
+		{name: "nvim-linux64/bin/nvim", body: "binary", mode: 0755},
+		{name: "nvim-linux64/share/nvim/runtime.vim", body: "runtime", mode: 0644},
+		{name: "nvim-linux64/README.md", body: "readme", mode: 0600},
+	}
+	archives := map[string]string{
+		"tar.gz": tarGzArchive(t, entries),
+		"tar.xz": tarXzArchive(t, entries),
+		"zip":    zipArchive(t, entries),
+	}
+	for format, archive := range archives {
+		t.Run(format, func(t *testing.T) {
+			to := t.TempDir()
+			err := ExtractArchive(archive, to, ExtractOptions{StripComponents: 1, Include: []string{"bin/*", "share"}})
+			require.NoError(t, err)
+
+			info, err := os.Stat(filepath.Join(to, "bin", "nvim"))
+			require.NoError(t, err)
+			assert.Equal(t, os.FileMode(0755), info.Mode().Perm())
+			body, err := os.ReadFile(filepath.Join(to, "share", "nvim", "runtime.vim"))
+			assert.NoError(t, err)
+			assert.Equal(t, "runtime", string(body))
+			_, err = os.Stat(filepath.Join(to, "README.md"))
+			assert.True(t, os.IsNotExist(err))
+		})
+	}
+}
+
+func TestExtractArchiveTraversal(t *testing.T) {
+	tests := map[string][]testEntry{
+		"parent paths": {{name: "../evil", body: "evil", mode: 0644}},
+		"absolute symlinks": {
+			{name: "link", typeflag: tar.TypeSymlink, linkname: "/etc"},
+		},
+		"relative symlinks": {
+			{name: "dir/link", typeflag: tar.TypeSymlink, linkname: "../../outside"},
+		},
+		"writing through a symlink": {
+			{name: "link", typeflag: tar.TypeSymlink, linkname: "."},
+			{name: "link/file", body: "evil", mode: 0644},
+		},
+	}
+	for name, entries := range tests {
+		t.Run(name, func(t *testing.T) {
+			parent := t.TempDir()
+			to := filepath.Join(parent, "to")
+			err := ExtractArchive(tarGzArchive(t, entries), to, ExtractOptions{})
+			assert.Error(t, err)
+			_, err = os.Stat(filepath.Join(parent, "evil"))
+			assert.True(t, os.IsNotExist(err))
+		})
+	}
+}
+
+func TestExtractFile(t *testing.T) {
+	archive := tarGzArchive(t, []testEntry{
+		{name: "ripgrep/doc/rg.1", body: "manual", mode: 0644},
+		{name: "ripgrep/rg", body: "binary", mode: 0644},
+	})
+	to := filepath.Join(t.TempDir(), "bin", "rg")
+	require.NoError(t, ExtractFile(archive, "rg", to))
+	info, err := os.Stat(to)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0755), info.Mode().Perm())
+
+	assert.Error(t, ExtractFile(archive, "fd", to))
+
+	// anything that isn't an archive is the binary itself
+	plain := filepath.Join(t.TempDir(), "kubectl")
+	require.NoError(t, os.WriteFile(plain, []byte("binary"), 0644))
+	require.NoError(t, ExtractFile(plain, "kubectl", to))
+	body, err := os.ReadFile(to)
+	assert.NoError(t, err)
+	assert.Equal(t, "binary", string(body))
+}
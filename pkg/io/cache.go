@@ -0,0 +1,232 @@
+package io
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+// this file is the download cache, which is shared across runs. Downloads are stored once by the sha256 of their
+// contents, and indexed by their url and the checksum they were expected to have.
+
+// CacheEntry describes a cached download
+type CacheEntry struct {
+	Key          string    `json:"-"`
+	URL          string    `json:"url"`
+	Checksum     string    `json:"checksum,omitempty"` // the checksum the download was expected to have, if any
+	Filename     string    `json:"filename"`
+	SHA256       string    `json:"sha256"` // the sha256 of the contents, which names the blob
+	Size         int64     `json:"size"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"`
+	LastUsed     time.Time `json:"last_used"`
+}
+
+// DownloadCache stores downloads under a directory
+type DownloadCache struct {
+	dir string
+}
+
+// DefaultCacheDir is $XDG_CACHE_HOME/envy/downloads, or ~/.cache/envy/downloads
+func DefaultCacheDir() (string, error) {
+	dir := os.Getenv("XDG_CACHE_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", xerrors.Errorf("unable to determine the cache directory: %v", err)
+		}
+		dir = filepath.Join(home, ".cache")
+	}
+	return filepath.Join(dir, "envy", "downloads"), nil
+}
+
+func NewDownloadCache(dir string) *DownloadCache {
+	return &DownloadCache{dir: dir}
+}
+
+// cacheKey identifies a download by its url, and the checksum it is expected to have
+func cacheKey(url, checksum string) string {
+	sum := sha256.Sum256([]byte(url + "\n" + strings.ToLower(checksum)))
+	return hex.EncodeToString(sum[:])
+}
+
+func (c *DownloadCache) indexPath(key string) string {
+	return filepath.Join(c.dir, "index", key+".json")
+}
+
+func (c *DownloadCache) blobPath(sha string) string {
+	return filepath.Join(c.dir, "blobs", sha)
+}
+
+// Get returns the cached download of the url, if there is one
+func (c *DownloadCache) Get(url, checksum string) (*CacheEntry, bool) {
+	key := cacheKey(url, checksum)
+	data, err := os.ReadFile(c.indexPath(key))
+	if err != nil {
+		return nil, false
+	}
+	entry := &CacheEntry{}
+	if err = json.Unmarshal(data, entry); err != nil {
+		return nil, false
+	}
+	entry.Key = key
+	if _, err = os.Stat(c.blobPath(entry.SHA256)); err != nil {
+		return nil, false
+	}
+	return entry, true
+}
+
+// CopyTo copies the contents of the cached download to the file
+func (c *DownloadCache) CopyTo(entry *CacheEntry, filename string) error {
+	if err := copyFile(c.blobPath(entry.SHA256), filename); err != nil {
+		return xerrors.Errorf("error copying from the cache: %w", err)
+	}
+	return c.Touch(entry)
+}
+
+// Touch records that the entry was used, so prune keeps it
+func (c *DownloadCache) Touch(entry *CacheEntry) error {
+	entry.LastUsed = time.Now()
+	return c.writeIndex(entry)
+}
+
+// Put adds the file to the cache
+func (c *DownloadCache) Put(entry CacheEntry, filename string) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return xerrors.Errorf("error reading the download: %v", err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	if err = os.MkdirAll(filepath.Join(c.dir, "blobs"), 0755); err != nil {
+		return xerrors.Errorf("error creating the cache directory: %v", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Join(c.dir, "blobs"), ".blob-*")
+	if err != nil {
+		return xerrors.Errorf("error writing to the cache: %v", err)
+	}
+	defer func() {
+		_ = os.Remove(tmp.Name())
+	}()
+	h := sha256.New()
+	entry.Size, err = io.Copy(io.MultiWriter(tmp, h), f)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return xerrors.Errorf("error writing to the cache: %v", err)
+	}
+	entry.SHA256 = hex.EncodeToString(h.Sum(nil))
+	if err = os.Rename(tmp.Name(), c.blobPath(entry.SHA256)); err != nil {
+		return xerrors.Errorf("error writing to the cache: %v", err)
+	}
+	entry.Key = cacheKey(entry.URL, entry.Checksum)
+	entry.LastUsed = time.Now()
+	return c.writeIndex(&entry)
+}
+
+func (c *DownloadCache) writeIndex(entry *CacheEntry) error {
+	data, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Join(c.dir, "index"), 0755); err != nil {
+		return xerrors.Errorf("error creating the cache directory: %v", err)
+	}
+	// write and rename, so concurrent runs never read half an entry
+	tmp, err := os.CreateTemp(filepath.Join(c.dir, "index"), ".entry-*")
+	if err != nil {
+		return xerrors.Errorf("error writing to the cache: %v", err)
+	}
+	defer func() {
+		_ = os.Remove(tmp.Name())
+	}()
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return xerrors.Errorf("error writing to the cache: %v", err)
+	}
+	return os.Rename(tmp.Name(), c.indexPath(entry.Key))
+}
+
+// List returns the cached downloads, most recently used first
+func (c *DownloadCache) List() ([]CacheEntry, error) {
+	files, err := os.ReadDir(filepath.Join(c.dir, "index"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, xerrors.Errorf("error reading the cache: %v", err)
+	}
+	var entries []CacheEntry
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), ".json") || strings.HasPrefix(f.Name(), ".") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(c.dir, "index", f.Name()))
+		if err != nil {
+			continue
+		}
+		entry := CacheEntry{}
+		if err = json.Unmarshal(data, &entry); err != nil {
+			continue
+		}
+		entry.Key = strings.TrimSuffix(f.Name(), ".json")
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].LastUsed.After(entries[j].LastUsed)
+	})
+	return entries, nil
+}
+
+// Prune removes the downloads that haven't been used since the cutoff, and returns how many bytes were freed.
+// A zero cutoff removes everything.
+func (c *DownloadCache) Prune(cutoff time.Time) (int64, error) {
+	entries, err := c.List()
+	if err != nil {
+		return 0, err
+	}
+	used := map[string]bool{}
+	for _, entry := range entries {
+		if !cutoff.IsZero() && entry.LastUsed.After(cutoff) {
+			used[entry.SHA256] = true
+			continue
+		}
+		if err = os.Remove(c.indexPath(entry.Key)); err != nil && !os.IsNotExist(err) {
+			return 0, xerrors.Errorf("error pruning the cache: %v", err)
+		}
+	}
+	// blobs are shared by entries with the same contents, so only remove the ones nothing refers to
+	blobs, err := os.ReadDir(filepath.Join(c.dir, "blobs"))
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, xerrors.Errorf("error reading the cache: %v", err)
+	}
+	var freed int64
+	for _, blob := range blobs {
+		if used[blob.Name()] || strings.HasPrefix(blob.Name(), ".") {
+			continue
+		}
+		if info, err := blob.Info(); err == nil {
+			freed += info.Size()
+		}
+		if err = os.Remove(c.blobPath(blob.Name())); err != nil && !os.IsNotExist(err) {
+			return freed, xerrors.Errorf("error pruning the cache: %v", err)
+		}
+	}
+	return freed, nil
+}
@@ -0,0 +1,121 @@
+package io
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDownloadCache(t *testing.T) {
+	c := NewDownloadCache(t.TempDir())
+	file := filepath.Join(t.TempDir(), "rg.tar.gz")
+	require.NoError(t, os.WriteFile(file, []byte("contents"), 0644))
+
+	_, ok := c.Get("https://example.com/rg.tar.gz", "")
+	assert.False(t, ok)
+
+	require.NoError(t, c.Put(CacheEntry{URL: "https://example.com/rg.tar.gz", Filename: "rg.tar.gz"}, file))
+	require.NoError(t, c.Put(CacheEntry{URL: "https://example.com/fd.tar.gz", Filename: "fd.tar.gz"}, file))
+	entry, ok := c.Get("https://example.com/rg.tar.gz", "")
+	require.True(t, ok)
+	assert.Equal(t, int64(8), entry.Size)
+	// the checksum is part of the key
+	_, ok = c.Get("https://example.com/rg.tar.gz", "abcd")
+	assert.False(t, ok)
+
+	to := filepath.Join(t.TempDir(), "copy")
+	require.NoError(t, c.CopyTo(entry, to))
+	body, err := os.ReadFile(to)
+	assert.NoError(t, err)
+	assert.Equal(t, "contents", string(body))
+
+	entries, err := c.List()
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, "https://example.com/rg.tar.gz", entries[0].URL)
+
+	t.Run("prune keeps blobs that are still used", func(t *testing.T) {
+		fd := entries[1]
+		fd.LastUsed = time.Now().Add(-48 * time.Hour)
+		require.NoError(t, c.writeIndex(&fd))
+		freed, err := c.Prune(time.Now().Add(-24 * time.Hour))
+		assert.NoError(t, err)
+		assert.Equal(t, int64(0), freed)
+		_, ok := c.Get("https://example.com/fd.tar.gz", "")
+		assert.False(t, ok)
+		_, ok = c.Get("https://example.com/rg.tar.gz", "")
+		assert.True(t, ok)
+	})
+
+	t.Run("prune everything", func(t *testing.T) {
+		freed, err := c.Prune(time.Time{})
+		assert.NoError(t, err)
+		assert.Equal(t, int64(8), freed)
+		entries, err := c.List()
+		assert.NoError(t, err)
+		assert.Empty(t, entries)
+	})
+}
+
+func TestCachedDownload(t *testing.T) {
+	contents := bytes.Repeat([]byte("0123456789"), 100)
+	sum := sha256.Sum256(contents)
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.Path)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		_, _ = w.Write(contents)
+	}))
+	defer server.Close()
+	cache := NewDownloadCache(t.TempDir())
+	d := NewCachedDownloader(cache, false, false, nil)
+	d.backoff = time.Millisecond
+
+	filename, err := d.Download(context.Background(), server.URL+"/rg.tar.gz", t.TempDir())
+	require.NoError(t, err)
+	assert.Equal(t, "rg.tar.gz", filepath.Base(filename))
+	assert.Equal(t, []string{"/rg.tar.gz"}, requests)
+
+	t.Run("unchanged downloads come from the cache", func(t *testing.T) {
+		filename, err := d.Download(context.Background(), server.URL+"/rg.tar.gz", t.TempDir())
+		require.NoError(t, err)
+		body, err := os.ReadFile(filename)
+		assert.NoError(t, err)
+		assert.Equal(t, contents, body)
+		assert.Len(t, requests, 2)
+	})
+
+	t.Run("downloads with a checksum are not requested again", func(t *testing.T) {
+		opt := WithChecksum(SHA256, hex.EncodeToString(sum[:]))
+		_, err := d.Download(context.Background(), server.URL+"/fd.tar.gz", t.TempDir(), opt)
+		require.NoError(t, err)
+		requests = nil
+		_, err = d.Download(context.Background(), server.URL+"/fd.tar.gz", t.TempDir(), opt)
+		require.NoError(t, err)
+		assert.Empty(t, requests)
+	})
+
+	t.Run("offline", func(t *testing.T) {
+		offline := NewCachedDownloader(cache, true, false, nil)
+		requests = nil
+		_, err := offline.Download(context.Background(), server.URL+"/rg.tar.gz", t.TempDir())
+		assert.NoError(t, err)
+		_, err = offline.Download(context.Background(), server.URL+"/missing.tar.gz", t.TempDir())
+		assert.Error(t, err)
+		assert.Empty(t, requests)
+	})
+}
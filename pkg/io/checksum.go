@@ -0,0 +1,85 @@
+package io
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"hash"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"golang.org/x/xerrors"
+)
+
+// Checksum algorithms supported by VerifyChecksum
+const (
+	SHA256 = "sha256"
+	SHA512 = "sha512"
+)
+
+// VerifyChecksum checks that the digest of the file matches the expected hex encoded digest
+func VerifyChecksum(filename, algorithm, expected string) error {
+	var h hash.Hash
+	switch strings.ToLower(algorithm) {
+	case SHA256:
+		h = sha256.New()
+	case SHA512:
+		h = sha512.New()
+	default:
+		return xerrors.Errorf("unsupported checksum algorithm `%v`", algorithm)
+	}
+	f, err := os.Open(filename)
+	if err != nil {
+		return xerrors.Errorf("error opening file to verify: %v", err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	if _, err = io.Copy(h, f); err != nil {
+		return xerrors.Errorf("error reading file to verify: %v", err)
+	}
+	actual := hex.EncodeToString(h.Sum(nil))
+	if !strings.EqualFold(actual, strings.TrimSpace(expected)) {
+		return xerrors.Errorf("checksum mismatch, expected %v `%v` but got `%v`", algorithm, expected, actual)
+	}
+	return nil
+}
+
+// ChecksumFromList finds the checksum of the file in a checksums file, in the format written by sha256sum and
+// sha512sum ("<hex>  <name>"), or the BSD format ("SHA256 (<name>) = <hex>"). The algorithm is determined by
+// the length of the checksum.
+func ChecksumFromList(list []byte, filename string) (algorithm string, checksum string, err error) {
+	filename = path.Base(filename)
+	scanner := bufio.NewScanner(bytes.NewReader(list))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		var name, sum string
+		if open := strings.Index(line, " ("); open > 0 && strings.Contains(line, ") = ") {
+			// BSD style
+			end := strings.LastIndex(line, ") = ")
+			name, sum = line[open+2:end], line[end+4:]
+		} else {
+			fields := strings.Fields(line)
+			if len(fields) != 2 {
+				continue
+			}
+			// binary mode files are marked with a leading "*"
+			sum, name = fields[0], strings.TrimPrefix(fields[1], "*")
+		}
+		if path.Base(name) != filename {
+			continue
+		}
+		switch len(sum) {
+		case sha256.Size * 2:
+			return SHA256, sum, nil
+		case sha512.Size * 2:
+			return SHA512, sum, nil
+		}
+		return "", "", xerrors.Errorf("the checksum of `%v` is not a sha256 or sha512 checksum", filename)
+	}
+	return "", "", xerrors.Errorf("`%v` is not in the checksums file", filename)
+}
@@ -0,0 +1,46 @@
+package io
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChecksumFromList(t *testing.T) {
+	sha256sum := "ee4e0751ab108b6da4f47c52da187d5177dc371f0f512a7caaec5434e711c091"
+	sha512sum := "cf83e1357eefb8bdf1542850d66d8007d620e4050b5715dc83f4a921d36ce9ce47d0d13c5d85f2b0ff8318d2877eec2f63b931bd47417a81a538327af927da3e"
+	list := []byte(sha256sum + "  ripgrep-13.0.0-x86_64-unknown-linux-musl.tar.gz\n" +
+		sha512sum + " *dist/fd.zip\n" +
+		"SHA256 (lazygit.tar.gz) = " + sha256sum + "\n")
+
+	algorithm, sum, err := ChecksumFromList(list, "ripgrep-13.0.0-x86_64-unknown-linux-musl.tar.gz")
+	assert.NoError(t, err)
+	assert.Equal(t, SHA256, algorithm)
+	assert.Equal(t, sha256sum, sum)
+
+	algorithm, sum, err = ChecksumFromList(list, "fd.zip")
+	assert.NoError(t, err)
+	assert.Equal(t, SHA512, algorithm)
+	assert.Equal(t, sha512sum, sum)
+
+	_, sum, err = ChecksumFromList(list, "lazygit.tar.gz")
+	assert.NoError(t, err)
+	assert.Equal(t, sha256sum, sum)
+
+	_, _, err = ChecksumFromList(list, "kubectl")
+	assert.Error(t, err)
+}
+
+func TestVerifyChecksum(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "file")
+	require.NoError(t, os.WriteFile(name, []byte("contents"), 0644))
+	sum := sha256.Sum256([]byte("contents"))
+	assert.NoError(t, VerifyChecksum(name, SHA256, hex.EncodeToString(sum[:])))
+	assert.Error(t, VerifyChecksum(name, SHA256, "00"))
+	assert.Error(t, VerifyChecksum(name, "md5", "00"))
+}
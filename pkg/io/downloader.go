@@ -2,8 +2,12 @@ package io
 
 import (
 	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
 	"io"
 	"mime"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
@@ -15,58 +19,403 @@ import (
 )
 
 type Downloader interface {
-	Download(ctx context.Context, from, to string) (string, error)
+	Download(ctx context.Context, from, to string, opts ...DownloadOption) (string, error)
+}
+
+// DownloadOption changes how a file is downloaded
+type DownloadOption func(o *downloadOptions)
+
+type downloadOptions struct {
+	verifiers []func(filename string) error
+	checksum  string // the expected checksum, which is part of the cache key
+	filename  string
+}
+
+// WithVerifier checks the downloaded file before it is moved into place. If it fails, the file is removed.
+func WithVerifier(verify func(filename string) error) DownloadOption {
+	return func(o *downloadOptions) {
+		o.verifiers = append(o.verifiers, verify)
+	}
+}
+
+// WithFilename names the downloaded file, instead of naming it after the source
+func WithFilename(name string) DownloadOption {
+	return func(o *downloadOptions) {
+		o.filename = name
+	}
+}
+
+// WithChecksum verifies the downloaded file has the expected sha256 or sha512 checksum
+func WithChecksum(algorithm, expected string) DownloadOption {
+	verify := WithVerifier(func(filename string) error {
+		return VerifyChecksum(filename, algorithm, expected)
+	})
+	return func(o *downloadOptions) {
+		verify(o)
+		o.checksum = expected
+	}
 }
 
 var _ Downloader = (*downloader)(nil)
 
-type downloader struct{}
+const (
+	defaultAttempts = 4
+	defaultBackoff  = time.Second
+)
+
+func NewDownloader() *downloader {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// honor HTTP_PROXY, HTTPS_PROXY and NO_PROXY
+	transport.Proxy = http.ProxyFromEnvironment
+	transport.DialContext = (&net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}).DialContext
+	transport.ResponseHeaderTimeout = 30 * time.Second
+	return &downloader{
+		// there is deliberately no total timeout, large files take as long as they take. Use the ctx to give up.
+		client:   &http.Client{Transport: transport},
+		attempts: defaultAttempts,
+		backoff:  defaultBackoff,
+	}
+}
+
+// NewCachedDownloader is a downloader that keeps its downloads in the cache. When offline, downloads can
+// only come from the cache. When verbose, it says which downloads come from the cache. The progress of every
+// download is reported to the observer, if there is one.
+func NewCachedDownloader(cache *DownloadCache, offline, verbose bool, observer ProgressObserver) *downloader {
+	d := NewDownloader()
+	d.cache = cache
+	d.offline = offline
+	d.verbose = verbose
+	d.observer = observer
+	return d
+}
+
+type downloader struct {
+	client   *http.Client
+	attempts int
+	backoff  time.Duration // doubled after every failed attempt
+	cache    *DownloadCache
+	offline  bool
+	verbose  bool
+	observer ProgressObserver
+}
+
+// errNotModified means the cached download is still current
+var errNotModified = errors.New("not modified")
+
+// retryableError is a failure that may succeed if the download is tried again
+type retryableError struct {
+	err error
+}
+
+func (e retryableError) Error() string {
+	return e.err.Error()
+}
+
+func (e retryableError) Unwrap() error {
+	return e.err
+}
+
+// Download copies a file 'from' the source location into the 'to' directory. The source is an http(s) url, a
+// file:// url or a local path. The file is named after the Content-Disposition header if the server sends one,
+// such as `Content-Disposition: attachment; filename="filename.jpg"`, and otherwise after the source, unless
+// WithFilename names it.
+// The file is written next to its destination first, and only moved into place once it has been verified.
+// Failed attempts are retried with backoff, and resume where they left off if the server supports ranges.
+func (d downloader) Download(ctx context.Context, from, to string, opts ...DownloadOption) (string, error) {
+	filename, size, err := d.download(ctx, from, to, opts...)
+	if d.observer != nil {
+		p := Progress{URL: from, Name: path.Base(from)}
+		if uri, parseErr := url.Parse(from); parseErr == nil {
+			p = newProgress(uri)
+		}
+		p.Bytes, p.Total, p.Done, p.Err = size, size, true, err
+		d.observer.Progress(p)
+	}
+	return filename, err
+}
 
-//Download copies a file 'from' the source online location and places
-//it at the local 'to' location. If the 'to' location is a directly
-//Content-Disposition: attachment; filename="filename.jpg"
-func (d downloader) Download(ctx context.Context, from, to string) (string, error) {
+func (d downloader) download(ctx context.Context, from, to string, opts ...DownloadOption) (string, int64, error) {
+	o := &downloadOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
 	uri, err := url.Parse(from)
 	if err != nil {
-		return "", xerrors.Errorf("error parsing url: %v", err)
+		return "", 0, xerrors.Errorf("error parsing url: %v", err)
+	}
+	dir := to
+	if dir == "" {
+		dir = os.TempDir()
+	}
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return "", 0, xerrors.Errorf("error creating the destination directory: %v", err)
+	}
+	// the partial download is named after the url, so an interrupted download can be resumed by the next run
+	sum := sha256.Sum256([]byte(uri.String()))
+	partial := path.Join(dir, fmt.Sprintf(".envy-%x.part", sum[:8]))
+
+	var filename string
+	var cached *CacheEntry
+	var header http.Header
+	switch uri.Scheme {
+	case "", "file":
+		source, err := localSource(from, uri)
+		if err != nil {
+			return "", 0, err
+		}
+		if err = copyFile(source, partial); err != nil {
+			removePartial(partial)
+			return "", 0, err
+		}
+		filename = path.Join(dir, path.Base(source))
+	default:
+		if cached, header, err = d.fetchOrCache(ctx, from, uri, partial, o); err != nil {
+			return "", 0, err
+		}
+		filename = determineFileName(dir, uri, header)
+		if cached != nil {
+			filename = path.Join(dir, cached.Filename)
+		}
 	}
-	client := http.Client{
-		Timeout: 10 * time.Second,
+	// temp files are only readable by their owner
+	if err = os.Chmod(partial, 0644); err != nil {
+		return "", 0, xerrors.Errorf("error setting the mode of the destination file: %v", err)
 	}
-	resp, err := client.Get(uri.String())
+	for _, verify := range o.verifiers {
+		if err = verify(partial); err != nil {
+			removePartial(partial)
+			return "", 0, xerrors.Errorf("error verifying `%v`: %w", from, err)
+		}
+	}
+	if d.cache != nil && cached == nil && header != nil {
+		err = d.cache.Put(CacheEntry{
+			URL:          uri.String(),
+			Checksum:     o.checksum,
+			Filename:     path.Base(filename),
+			ETag:         header.Get("ETag"),
+			LastModified: header.Get("Last-Modified"),
+		}, partial)
+		if err != nil {
+			PrintWarningF("unable to cache `%v`: %v", from, err)
+		}
+	}
+	if len(o.filename) > 0 {
+		filename = path.Join(dir, o.filename)
+	}
+	if err = os.Rename(partial, filename); err != nil {
+		return "", 0, xerrors.Errorf("error moving the download into place: %v", err)
+	}
+	_ = os.Remove(validatorFile(partial))
+	info, err := os.Stat(filename)
 	if err != nil {
-		return "", xerrors.Errorf("error getting the url: %v", err)
+		return "", 0, xerrors.Errorf("error reading the download: %v", err)
 	}
-	filename := determineFileName(ctx, to, resp.Header)
-	//TODO (@morgan): probably need to make the folder if it doesn't exist
-	//TODO (@morgan): this should also be an interface we control so we can mock it
-	f, err := os.Create(filename)
+	return filename, info.Size(), nil
+}
+
+// fetchOrCache downloads the url into the partial file, or copies it from the cache when the cached download is
+// known to be current. The cache entry is returned if it was used, and the response headers otherwise.
+func (d downloader) fetchOrCache(ctx context.Context, from string, uri *url.URL, partial string, o *downloadOptions) (*CacheEntry, http.Header, error) {
+	var cached *CacheEntry
+	if d.cache != nil {
+		cached, _ = d.cache.Get(uri.String(), o.checksum)
+	}
+	if cached == nil && d.offline {
+		return nil, nil, xerrors.Errorf("`%v` is not in the download cache, and envy is offline", from)
+	}
+	// with a checksum, the cached contents are known to be right, so there is no need to ask the server
+	fromCache := cached != nil && (d.offline || o.checksum != "")
+	var header http.Header
+	var err error
+	backoff := d.backoff
+	for attempt := 1; !fromCache; attempt++ {
+		header, err = d.fetch(ctx, uri, partial, cached)
+		if err == errNotModified {
+			fromCache = true
+			break
+		}
+		if err == nil {
+			return nil, header, nil
+		}
+		var retryable retryableError
+		if !errors.As(err, &retryable) {
+			// there is nothing to resume, so don't leave the partial file next to the target
+			removePartial(partial)
+			return nil, nil, xerrors.Errorf("error downloading `%v`: %w", from, err)
+		}
+		if attempt >= d.attempts || ctx.Err() != nil {
+			return nil, nil, xerrors.Errorf("error downloading `%v`: %w", from, err)
+		}
+		PrintWarningF("downloading `%v` failed, retrying in %v: %v", from, backoff, err)
+		select {
+		case <-ctx.Done():
+			return nil, nil, xerrors.Errorf("error downloading `%v`: %w", from, ctx.Err())
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+	PrintVerboseF(d.verbose, "using the cached download of `%v`", from)
+	if err = d.cache.CopyTo(cached, partial); err != nil {
+		return nil, nil, err
+	}
+	return cached, nil, nil
+}
+
+// localSource is the path of a file:// url, or a local path
+func localSource(from string, uri *url.URL) (string, error) {
+	if uri.Scheme == "" {
+		return from, nil
+	}
+	if uri.Host != "" && uri.Host != "localhost" {
+		return "", xerrors.Errorf("file urls on other hosts are not supported: `%v`", from)
+	}
+	return uri.Path, nil
+}
+
+// copyFile copies the contents of a local file
+func copyFile(from, to string) error {
+	in, err := os.Open(from)
 	if err != nil {
-		return "", xerrors.Errorf("error creating destination file: %v", err)
+		return xerrors.Errorf("error opening `%v`: %v", from, err)
 	}
 	defer func() {
-		_ = f.Close()
+		_ = in.Close()
 	}()
-	_, err = io.Copy(f, resp.Body)
+	out, err := os.OpenFile(to, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return xerrors.Errorf("error creating destination file: %v", err)
+	}
+	_, err = io.Copy(out, in)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return xerrors.Errorf("error copying `%v`: %v", from, err)
+	}
+	return nil
+}
+
+// validatorFile keeps the ETag or Last-Modified of a partial download, so that it's only resumed while the file
+// on the server is the same
+func validatorFile(partial string) string {
+	return partial + ".validator"
+}
+
+// removePartial removes a partial download, and its validator
+func removePartial(partial string) {
+	_ = os.Remove(partial)
+	_ = os.Remove(validatorFile(partial))
+}
+
+// validator returns the header that identifies this version of the file for If-Range, a strong ETag or the
+// Last-Modified date
+func validator(header http.Header) string {
+	if etag := header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		return etag
+	}
+	return header.Get("Last-Modified")
+}
+
+// fetch downloads the url into the partial file, resuming from its current size if the server allows it and the
+// file hasn't changed since. If there is a cached download, the server is asked if it is still current.
+func (d downloader) fetch(ctx context.Context, uri *url.URL, partial string, cached *CacheEntry) (http.Header, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri.String(), nil)
+	if err != nil {
+		return nil, xerrors.Errorf("error creating the request: %v", err)
+	}
+	var offset int64
+	info, err := os.Stat(partial)
+	// without a validator the partial file could be from another version of the file, so it starts over
+	if v, _ := os.ReadFile(validatorFile(partial)); err == nil && info.Size() > 0 && len(v) > 0 {
+		offset = info.Size()
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		req.Header.Set("If-Range", string(v))
+	} else if cached != nil {
+		if cached.ETag != "" {
+			req.Header.Set("If-None-Match", cached.ETag)
+		}
+		if cached.LastModified != "" {
+			req.Header.Set("If-Modified-Since", cached.LastModified)
+		}
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return nil, retryableError{xerrors.Errorf("error getting the url: %v", err)}
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	flags := os.O_CREATE | os.O_WRONLY
+	switch {
+	case resp.StatusCode == http.StatusNotModified && cached != nil:
+		return resp.Header, errNotModified
+	case resp.StatusCode == http.StatusPartialContent && offset > 0:
+		flags |= os.O_APPEND
+	case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable && offset > 0:
+		// the partial file is no use, start over
+		removePartial(partial)
+		return nil, retryableError{xerrors.Errorf("unexpected response `%v` resuming at byte %v", resp.Status, offset)}
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		// the server ignored the range, the file changed, or there was no range
+		flags |= os.O_TRUNC
+		if v := validator(resp.Header); v != "" {
+			if err = os.WriteFile(validatorFile(partial), []byte(v), 0600); err != nil {
+				return nil, xerrors.Errorf("error saving the validator of the download: %v", err)
+			}
+		} else {
+			_ = os.Remove(validatorFile(partial))
+		}
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return nil, retryableError{xerrors.Errorf("unexpected response `%v`", resp.Status)}
+	default:
+		return nil, xerrors.Errorf("unexpected response `%v`", resp.Status)
+	}
+
+	f, err := os.OpenFile(partial, flags, 0600)
+	if err != nil {
+		return nil, xerrors.Errorf("error creating destination file: %v", err)
+	}
+	var w io.Writer = f
+	if d.observer != nil {
+		total := int64(-1)
+		if resp.ContentLength >= 0 {
+			total = resp.ContentLength
+			if flags&os.O_APPEND != 0 {
+				total += offset
+			}
+		}
+		p := newProgress(uri)
+		p.Total = total
+		if flags&os.O_APPEND != 0 {
+			p.Bytes = offset
+		}
+		w = io.MultiWriter(f, newProgressWriter(d.observer, p))
+	}
+	_, err = io.Copy(w, resp.Body)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
 	if err != nil {
-		return "", xerrors.Errorf("error copying file contents to destination file: %v", err)
+		return nil, retryableError{xerrors.Errorf("error copying file contents to destination file: %v", err)}
 	}
-	return filename, nil
+	return resp.Header, nil
 }
 
-//determine filename places the file in targetDir/filename, where filename
-//is extracted from the http header, or set to a default value
-func determineFileName(ctx context.Context, targetDir string, header http.Header) string {
-	folder := "/tmp/"
-	name := "envy-file.tmp"
-	if targetDir != "" {
-		folder = targetDir
+// determineFileName places the file in targetDir/filename, where filename is extracted from the http header,
+// or the url
+func determineFileName(targetDir string, uri *url.URL, header http.Header) string {
+	name := extractHeaderFilename(header)
+	if name == "" {
+		name = path.Base(uri.Path)
 	}
-	headerName := extractHeaderFilename(header)
-	if headerName != "" {
-		name = headerName
+	if name == "" || name == "." || name == "/" {
+		name = "envy-download"
 	}
-	return path.Join(folder, name)
+	return path.Join(targetDir, name)
 }
 
 func extractHeaderFilename(header http.Header) string {
@@ -82,6 +431,11 @@ func extractHeaderFilename(header http.Header) string {
 		return ""
 	}
 	if filename, ok := params["filename"]; ok {
+		// never let the server choose a path outside of the target directory
+		filename = path.Base(strings.ReplaceAll(filename, "\\", "/"))
+		if filename == "." || filename == ".." || filename == "/" {
+			return ""
+		}
 		return filename
 	}
 	return ""
@@ -0,0 +1,155 @@
+package io
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testDownloader() *downloader {
+	d := NewDownloader()
+	d.backoff = time.Millisecond
+	return d
+}
+
+func TestDownload(t *testing.T) {
+	contents := bytes.Repeat([]byte("0123456789"), 1000)
+	var ranges []string
+	failures := 0
+	changed := append(bytes.Repeat([]byte("9876543210"), 999), []byte("changed!!!")...)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Range"))
+		switch r.URL.Path {
+		case "/missing":
+			w.WriteHeader(http.StatusNotFound)
+		case "/flaky":
+			if failures < 2 {
+				failures++
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			_, _ = w.Write(contents)
+		case "/interrupted", "/changed", "/unvalidated", "/gone":
+			if r.URL.Path != "/unvalidated" {
+				w.Header().Set("ETag", `"v1"`)
+			}
+			if r.Header.Get("Range") == "" {
+				// send half of the file, then drop the connection
+				w.Header().Set("Content-Length", strconv.Itoa(len(contents)))
+				_, _ = w.Write(contents[:len(contents)/2])
+				w.(http.Flusher).Flush()
+				panic(http.ErrAbortHandler)
+			}
+			switch r.URL.Path {
+			case "/changed":
+				// a new version of the file, which must not be spliced onto the old one
+				w.Header().Set("ETag", `"v2"`)
+				http.ServeContent(w, r, "file", time.Time{}, bytes.NewReader(changed))
+			case "/gone":
+				w.WriteHeader(http.StatusNotFound)
+			default:
+				http.ServeContent(w, r, "file", time.Time{}, bytes.NewReader(contents))
+			}
+		case "/named":
+			w.Header().Set("Content-Disposition", `attachment; filename="../../evil.sh"`)
+			_, _ = w.Write(contents)
+		}
+	}))
+	defer server.Close()
+	d := testDownloader()
+
+	t.Run("non 2xx responses fail without retrying", func(t *testing.T) {
+		ranges = nil
+		to := t.TempDir()
+		_, err := d.Download(context.Background(), server.URL+"/missing", to)
+		assert.Error(t, err)
+		assert.Len(t, ranges, 1)
+		entries, _ := os.ReadDir(to)
+		assert.Empty(t, entries)
+	})
+
+	t.Run("server errors are retried", func(t *testing.T) {
+		filename, err := d.Download(context.Background(), server.URL+"/flaky", t.TempDir())
+		require.NoError(t, err)
+		body, err := os.ReadFile(filename)
+		assert.NoError(t, err)
+		assert.Equal(t, contents, body)
+	})
+
+	t.Run("interrupted downloads resume", func(t *testing.T) {
+		ranges = nil
+		filename, err := d.Download(context.Background(), server.URL+"/interrupted", t.TempDir())
+		require.NoError(t, err)
+		assert.Equal(t, []string{"", "bytes=" + strconv.Itoa(len(contents)/2) + "-"}, ranges)
+		body, err := os.ReadFile(filename)
+		assert.NoError(t, err)
+		assert.Equal(t, contents, body)
+		entries, _ := os.ReadDir(filepath.Dir(filename))
+		assert.Len(t, entries, 1)
+	})
+
+	t.Run("downloads of a file that changed start over", func(t *testing.T) {
+		filename, err := d.Download(context.Background(), server.URL+"/changed", t.TempDir())
+		require.NoError(t, err)
+		body, err := os.ReadFile(filename)
+		assert.NoError(t, err)
+		assert.Equal(t, changed, body)
+	})
+
+	t.Run("downloads without a validator start over", func(t *testing.T) {
+		ranges = nil
+		_, err := d.Download(context.Background(), server.URL+"/unvalidated", t.TempDir())
+		assert.Error(t, err)
+		assert.Len(t, ranges, d.attempts)
+		for _, r := range ranges {
+			assert.Empty(t, r)
+		}
+	})
+
+	t.Run("failed downloads don't leave the partial file behind", func(t *testing.T) {
+		to := t.TempDir()
+		_, err := d.Download(context.Background(), server.URL+"/gone", to)
+		assert.Error(t, err)
+		entries, _ := os.ReadDir(to)
+		assert.Empty(t, entries)
+	})
+
+	t.Run("missing directories are created, and servers can't escape them", func(t *testing.T) {
+		to := filepath.Join(t.TempDir(), "a", "b")
+		filename, err := d.Download(context.Background(), server.URL+"/named", to)
+		require.NoError(t, err)
+		assert.Equal(t, filepath.Join(to, "evil.sh"), filename)
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := d.Download(ctx, server.URL+"/flaky", t.TempDir())
+		assert.Error(t, err)
+	})
+
+	t.Run("local files", func(t *testing.T) {
+		source := filepath.Join(t.TempDir(), "tool.sh")
+		require.NoError(t, os.WriteFile(source, contents, 0755))
+		for _, from := range []string{source, "file://" + source} {
+			to := t.TempDir()
+			filename, err := d.Download(context.Background(), from, to, WithFilename("tool"))
+			require.NoError(t, err)
+			assert.Equal(t, filepath.Join(to, "tool"), filename)
+			body, err := os.ReadFile(filename)
+			assert.NoError(t, err)
+			assert.Equal(t, contents, body)
+		}
+		_, err := d.Download(context.Background(), "file://example.com/tool.sh", t.TempDir())
+		assert.Error(t, err)
+	})
+}
@@ -83,3 +83,16 @@ func (f filesystem) Symlink(from, to string) error {
 func (f filesystem) Stat(name string) (os.FileInfo, error) {
 	return os.Stat(name)
 }
+
+// StateDir is where envy keeps its state, $XDG_STATE_HOME/envy or ~/.local/state/envy
+func StateDir() (string, error) {
+	dir := os.Getenv("XDG_STATE_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", xerrors.Errorf("unable to determine the state directory: %v", err)
+		}
+		dir = filepath.Join(home, ".local", "state")
+	}
+	return filepath.Join(dir, "envy"), nil
+}
@@ -2,6 +2,7 @@ package io
 
 import (
 	"fmt"
+	"os"
 	"runtime"
 )
 
@@ -37,3 +38,8 @@ func PrintVerboseError(verbose bool, err error) {
 		fmt.Printf("called from: %v:%v, error encountered: %v\n", file, line, err)
 	}
 }
+
+// PrintWarningF always prints, to stderr
+func PrintWarningF(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "warning: %v\n", fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,85 @@
+package io
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"time"
+)
+
+// Progress is reported while a file downloads
+type Progress struct {
+	URL   string
+	Name  string  // the name of the file, for display
+	Bytes int64   // downloaded so far, including anything resumed from an earlier attempt
+	Total int64   // the size of the file, or -1 if the server didn't say
+	Rate  float64 // bytes per second
+	Done  bool
+	Err   error // why the download failed, when it's done
+}
+
+// ProgressObserver receives the progress of downloads. Downloads can run in parallel, so it must be safe for
+// concurrent use.
+type ProgressObserver interface {
+	Progress(p Progress)
+}
+
+func newProgress(uri *url.URL) Progress {
+	return Progress{URL: uri.String(), Name: path.Base(uri.Path), Total: -1}
+}
+
+// progressInterval is how often a download reports its progress
+const progressInterval = 100 * time.Millisecond
+
+// progressWriter counts the bytes written through it, and reports them to the observer
+type progressWriter struct {
+	observer ProgressObserver
+	progress Progress
+	started  time.Time
+	resumed  int64 // bytes that were already downloaded when this attempt started
+	reported time.Time
+}
+
+func newProgressWriter(observer ProgressObserver, p Progress) *progressWriter {
+	now := time.Now()
+	w := &progressWriter{observer: observer, progress: p, started: now, resumed: p.Bytes, reported: now}
+	observer.Progress(p)
+	return w
+}
+
+func (w *progressWriter) Write(b []byte) (int, error) {
+	w.progress.Bytes += int64(len(b))
+	if now := time.Now(); now.Sub(w.reported) >= progressInterval {
+		w.reported = now
+		w.progress.Rate = float64(w.progress.Bytes-w.resumed) / now.Sub(w.started).Seconds()
+		w.observer.Progress(w.progress)
+	}
+	return len(b), nil
+}
+
+// FormatProgress describes the progress in a line, like "nvim.tar.gz  4.0 MiB / 10.0 MiB (40%)  1.2 MiB/s"
+func FormatProgress(p Progress) string {
+	switch {
+	case p.Err != nil:
+		return fmt.Sprintf("%v  failed: %v", p.Name, p.Err)
+	case p.Done:
+		return fmt.Sprintf("%v  %v  done", p.Name, formatBytes(p.Bytes))
+	case p.Total > 0:
+		return fmt.Sprintf("%v  %v / %v (%d%%)  %v/s", p.Name, formatBytes(p.Bytes), formatBytes(p.Total),
+			p.Bytes*100/p.Total, formatBytes(int64(p.Rate)))
+	}
+	return fmt.Sprintf("%v  %v  %v/s", p.Name, formatBytes(p.Bytes), formatBytes(int64(p.Rate)))
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
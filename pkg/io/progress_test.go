@@ -0,0 +1,74 @@
+package io
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type progressRecorder struct {
+	mu     sync.Mutex
+	events []Progress
+}
+
+func (r *progressRecorder) Progress(p Progress) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, p)
+}
+
+func TestDownloadProgress(t *testing.T) {
+	contents := bytes.Repeat([]byte("0123456789"), 1000)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", strconv.Itoa(len(contents)))
+		_, _ = w.Write(contents)
+	}))
+	defer server.Close()
+	recorder := &progressRecorder{}
+	d := testDownloader()
+	d.observer = recorder
+
+	_, err := d.Download(context.Background(), server.URL+"/nvim.tar.gz", t.TempDir())
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, len(recorder.events), 2)
+	first, last := recorder.events[0], recorder.events[len(recorder.events)-1]
+	assert.Equal(t, Progress{URL: server.URL + "/nvim.tar.gz", Name: "nvim.tar.gz", Total: int64(len(contents))}, first)
+	assert.True(t, last.Done)
+	assert.NoError(t, last.Err)
+	assert.Equal(t, int64(len(contents)), last.Bytes)
+}
+
+func TestTerminalProgress(t *testing.T) {
+	var out bytes.Buffer
+	term := &terminal{out: &out, tty: true}
+	term.Progress(Progress{URL: "a", Name: "a.tar.gz", Bytes: 50, Total: 100})
+	term.Progress(Progress{URL: "b", Name: "b.zip", Bytes: 0, Total: -1})
+	term.Progress(Progress{URL: "a", Name: "a.tar.gz", Bytes: 100, Total: 100, Done: true})
+	out.Reset()
+	term.Progress(Progress{URL: "b", Name: "b.zip", Bytes: 2048, Total: 2048, Done: true})
+
+	// both downloads are redrawn in place, each on its own line
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	require.Len(t, lines, 2)
+	assert.True(t, strings.HasPrefix(lines[0], "\x1b[2A"))
+	assert.Contains(t, lines[0], "a.tar.gz  100 B  done")
+	assert.Contains(t, lines[1], "b.zip  2.0 KiB  done")
+	// once they are all done, the next download starts a new line
+	out.Reset()
+	term.Progress(Progress{URL: "c", Name: "c", Total: -1})
+	assert.NotContains(t, out.String(), "\x1b[2A")
+}
+
+func TestFormatProgress(t *testing.T) {
+	assert.Equal(t, "nvim.tar.gz  4.0 MiB / 10.0 MiB (40%)  1.5 MiB/s",
+		FormatProgress(Progress{Name: "nvim.tar.gz", Bytes: 4 << 20, Total: 10 << 20, Rate: 1.5 * (1 << 20)}))
+	assert.Equal(t, "rg  512 B  0 B/s", FormatProgress(Progress{Name: "rg", Bytes: 512, Total: -1}))
+}
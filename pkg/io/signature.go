@@ -0,0 +1,99 @@
+package io
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/blake2b"
+	"golang.org/x/xerrors"
+)
+
+// this file verifies minisign and signify signatures, which are both Ed25519 signatures with the same key format
+
+const (
+	trustedCommentPrefix = "trusted comment: "
+	keyNumLength         = 8
+)
+
+var (
+	algEd25519          = []byte("Ed") // signs the file itself
+	algEd25519Prehashed = []byte("ED") // minisign only, signs the blake2b-512 hash of the file
+)
+
+// VerifySignature verifies the minisign or signify signature of the file, against the base64 encoded public key.
+// The public key may include its "untrusted comment:" line.
+func VerifySignature(filename string, signature []byte, publicKey string) error {
+	key, keyNum, err := decodePublicKey(publicKey)
+	if err != nil {
+		return err
+	}
+	lines := signatureLines(signature)
+	if len(lines) == 0 {
+		return xerrors.New("the signature is empty")
+	}
+	sig, err := base64.StdEncoding.DecodeString(lines[0])
+	if err != nil || len(sig) != 2+keyNumLength+ed25519.SignatureSize {
+		return xerrors.New("the signature is not a minisign or signify signature")
+	}
+	alg, sigKeyNum, sig := sig[:2], sig[2:2+keyNumLength], sig[2+keyNumLength:]
+	if !bytes.Equal(sigKeyNum, keyNum) {
+		return xerrors.New("the file was signed with a different key")
+	}
+
+	message, err := os.ReadFile(filename)
+	if err != nil {
+		return xerrors.Errorf("error reading file to verify: %v", err)
+	}
+	switch {
+	case bytes.Equal(alg, algEd25519):
+	case bytes.Equal(alg, algEd25519Prehashed):
+		sum := blake2b.Sum512(message)
+		message = sum[:]
+	default:
+		return xerrors.Errorf("unsupported signature algorithm `%s`", alg)
+	}
+	if !ed25519.Verify(key, message, sig) {
+		return xerrors.New("signature verification failed")
+	}
+
+	// minisign signatures also sign their trusted comment
+	if len(lines) >= 3 && strings.HasPrefix(lines[1], trustedCommentPrefix) {
+		globalSig, err := base64.StdEncoding.DecodeString(lines[2])
+		if err != nil || len(globalSig) != ed25519.SignatureSize {
+			return xerrors.New("the trusted comment signature is invalid")
+		}
+		comment := strings.TrimPrefix(lines[1], trustedCommentPrefix)
+		if !ed25519.Verify(key, append(append([]byte{}, sig...), comment...), globalSig) {
+			return xerrors.New("trusted comment verification failed")
+		}
+	}
+	return nil
+}
+
+func decodePublicKey(publicKey string) (ed25519.PublicKey, []byte, error) {
+	lines := signatureLines([]byte(publicKey))
+	if len(lines) == 0 {
+		return nil, nil, xerrors.New("the public key is empty")
+	}
+	key, err := base64.StdEncoding.DecodeString(lines[0])
+	if err != nil || len(key) != 2+keyNumLength+ed25519.PublicKeySize || !bytes.Equal(key[:2], algEd25519) {
+		return nil, nil, xerrors.New("the public key is not a minisign or signify public key")
+	}
+	return key[2+keyNumLength:], key[2 : 2+keyNumLength], nil
+}
+
+// signatureLines returns the non empty lines, without the untrusted comment
+func signatureLines(data []byte) []string {
+	var lines []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "untrusted comment:") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
@@ -0,0 +1,64 @@
+package io
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/blake2b"
+)
+
+// testSigner signs files in the minisign and signify formats
+type testSigner struct {
+	key    ed25519.PrivateKey
+	keyNum []byte
+}
+
+func newTestSigner(t *testing.T) (*testSigner, string) {
+	pub, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	s := &testSigner{key: key, keyNum: []byte("12345678")}
+	publicKey := "untrusted comment: minisign public key\n" +
+		base64.StdEncoding.EncodeToString(append(append([]byte("Ed"), s.keyNum...), pub...)) + "\n"
+	return s, publicKey
+}
+
+func (s *testSigner) signify(message []byte) []byte {
+	sig := ed25519.Sign(s.key, message)
+	return []byte("untrusted comment: verify with key.pub\n" +
+		base64.StdEncoding.EncodeToString(append(append([]byte("Ed"), s.keyNum...), sig...)) + "\n")
+}
+
+func (s *testSigner) minisign(message []byte, comment string) []byte {
+	hash := blake2b.Sum512(message)
+	sig := ed25519.Sign(s.key, hash[:])
+	global := ed25519.Sign(s.key, append(append([]byte{}, sig...), comment...))
+	return []byte("untrusted comment: signature from minisign secret key\n" +
+		base64.StdEncoding.EncodeToString(append(append([]byte("ED"), s.keyNum...), sig...)) + "\n" +
+		"trusted comment: " + comment + "\n" +
+		base64.StdEncoding.EncodeToString(global) + "\n")
+}
+
+func TestVerifySignature(t *testing.T) {
+	signer, publicKey := newTestSigner(t)
+	_, otherKey := newTestSigner(t)
+	message := []byte("#!/bin/sh\necho hello\n")
+	name := filepath.Join(t.TempDir(), "file")
+	require.NoError(t, os.WriteFile(name, message, 0644))
+
+	assert.NoError(t, VerifySignature(name, signer.signify(message), publicKey))
+	assert.NoError(t, VerifySignature(name, signer.minisign(message, "timestamp:1650000000"), publicKey))
+
+	assert.Error(t, VerifySignature(name, signer.signify([]byte("tampered")), publicKey))
+	assert.Error(t, VerifySignature(name, signer.minisign(message, "timestamp:1650000000"), otherKey))
+
+	// the trusted comment is signed as well
+	tampered := strings.Replace(string(signer.minisign(message, "timestamp:1650000000")), "1650000000", "1750000000", 1)
+	assert.Error(t, VerifySignature(name, []byte(tampered), publicKey))
+}
@@ -1,8 +1,16 @@
 package io
 
 import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
 	"github.com/AlecAivazis/survey/v2"
 	"go.uber.org/zap"
+	"golang.org/x/term"
 )
 
 type LogLevel string
@@ -20,23 +28,112 @@ type Terminal interface {
 	PromptUser(prompt string, options []string, defaultSelection string) (string, error)
 	//Tightly coupled interface just so we can moq it
 	AskOne(p survey.Prompt, response interface{}, opts ...survey.AskOpt) error
+	//Progress renders download progress, as bars on a tty and as periodic log lines otherwise
+	ProgressObserver
 }
 
 func NewTerminal() *terminal {
 	return &terminal{
 		Logger: NewLogger(),
+		out:    os.Stderr,
+		tty:    term.IsTerminal(int(os.Stderr.Fd())),
 	}
 }
 
 type terminal struct {
 	Logger
+	out io.Writer
+	tty bool
+
+	mu        sync.Mutex
+	downloads []Progress           // the downloads being drawn, one line each, in the order they started
+	drawn     int                  // how many lines were drawn last time
+	logged    map[string]time.Time // when each download was last logged, when not on a tty
+}
+
+// progressLogInterval is how often a download is logged when not on a tty
+const progressLogInterval = 5 * time.Second
+
+func (t *terminal) Progress(p Progress) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.tty {
+		t.logProgress(p)
+		return
+	}
+	found := false
+	for i := range t.downloads {
+		if t.downloads[i].URL == p.URL {
+			t.downloads[i] = p
+			found = true
+		}
+	}
+	if !found {
+		t.downloads = append(t.downloads, p)
+	}
+	// move back up over the previous lines, and draw them again
+	if t.drawn > 0 {
+		fmt.Fprintf(t.out, "\x1b[%dA", t.drawn)
+	}
+	done := true
+	for _, d := range t.downloads {
+		fmt.Fprintf(t.out, "\r\x1b[K%v\n", progressBar(d))
+		done = done && d.Done
+	}
+	t.drawn = len(t.downloads)
+	// once everything is finished the lines stay as they are, and the next downloads start below them
+	if done {
+		t.downloads = nil
+		t.drawn = 0
+	}
 }
 
-func (t terminal) PromptUser(prompt string, options []string, defaultSelection string) (string, error) {
-	panic("implement me")
+func (t *terminal) logProgress(p Progress) {
+	if t.logged == nil {
+		t.logged = map[string]time.Time{}
+	}
+	last, started := t.logged[p.URL]
+	if p.Done {
+		delete(t.logged, p.URL)
+	} else if started && time.Since(last) < progressLogInterval {
+		return
+	} else {
+		t.logged[p.URL] = time.Now()
+	}
+	t.Infof("downloading %v", FormatProgress(p))
+}
+
+const progressBarWidth = 30
+
+func progressBar(p Progress) string {
+	filled := progressBarWidth
+	if !p.Done && p.Total > 0 {
+		filled = int(p.Bytes * progressBarWidth / p.Total)
+	} else if !p.Done {
+		// the size isn't known, so there is nothing to fill
+		filled = 0
+	}
+	if filled > progressBarWidth {
+		filled = progressBarWidth
+	}
+	return fmt.Sprintf("[%v%v] %v", strings.Repeat("=", filled), strings.Repeat(" ", progressBarWidth-filled), FormatProgress(p))
+}
+
+func (t *terminal) PromptUser(prompt string, options []string, defaultSelection string) (string, error) {
+	selected := ""
+	p := &survey.Select{
+		Message:  prompt,
+		Options:  options,
+		PageSize: 15,
+	}
+	if len(defaultSelection) > 0 {
+		p.Default = defaultSelection
+	}
+	err := survey.AskOne(p, &selected)
+	return selected, err
 }
 
-func (t terminal) AskOne(p survey.Prompt, response interface{}, opts ...survey.AskOpt) error {
+func (t *terminal) AskOne(p survey.Prompt, response interface{}, opts ...survey.AskOpt) error {
 	return survey.AskOne(p, response)
 }
 
@@ -0,0 +1,110 @@
+package manager
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"golang.org/x/xerrors"
+	"gopkg.in/yaml.v3"
+)
+
+// this file decodes download entries, which can be a ["from", "to"] pair, or a table with options.
+// A pair with the wrong number of parameters decodes to an empty download, which validateRecipe reports.
+
+// downloadsTable has the fields of Downloads, without its decoding methods
+type downloadsTable Downloads
+
+func (d *Downloads) fromPair(pair []string) {
+	*d = Downloads{}
+	if len(pair) == 2 {
+		d.From, d.To = pair[0], pair[1]
+	}
+}
+
+// fromTable decodes the JSON of a download table. Unknown keys are rejected, since a misspelled key would otherwise
+// be dropped without a word.
+func (d *Downloads) fromTable(data []byte) error {
+	t := downloadsTable{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&t); err != nil {
+		return xerrors.Errorf("invalid download: %v", strings.TrimPrefix(err.Error(), "json: "))
+	}
+	*d = Downloads(t)
+	return nil
+}
+
+// urlVars are the variables the urls of the download see, where the os and arch are mapped to the names the
+// download uses
+func (d Downloads) urlVars(vars envVariables) envVariables {
+	if len(d.OS) == 0 && len(d.Arch) == 0 {
+		return vars
+	}
+	urlVars := vars.copy()
+	if v, ok := d.OS[vars[FACT_OS]]; ok {
+		urlVars[FACT_OS] = v
+	}
+	if v, ok := d.Arch[vars[FACT_ARCH]]; ok {
+		urlVars[FACT_ARCH] = v
+	}
+	return urlVars
+}
+
+// UnmarshalTOML decodes a download in a TOML recipe
+func (d *Downloads) UnmarshalTOML(data interface{}) error {
+	switch v := data.(type) {
+	case []interface{}:
+		pair := make([]string, 0, len(v))
+		for _, part := range v {
+			s, ok := part.(string)
+			if !ok {
+				return xerrors.Errorf("download parameters must be strings, not `%v`", part)
+			}
+			pair = append(pair, s)
+		}
+		d.fromPair(pair)
+		return nil
+	case map[string]interface{}:
+		// round trip through JSON, which has the same keys and is far simpler than walking the table
+		b, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		return d.fromTable(b)
+	}
+	return xerrors.Errorf("a download must be a pair of the source and the target, or a table, not `%v`", fmt.Sprint(data))
+}
+
+// UnmarshalJSON decodes a download in a JSON recipe
+func (d *Downloads) UnmarshalJSON(data []byte) error {
+	var pair []string
+	if err := json.Unmarshal(data, &pair); err == nil {
+		d.fromPair(pair)
+		return nil
+	}
+	return d.fromTable(data)
+}
+
+// UnmarshalYAML decodes a download in a YAML recipe
+func (d *Downloads) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.SequenceNode {
+		var pair []string
+		if err := value.Decode(&pair); err != nil {
+			return err
+		}
+		d.fromPair(pair)
+		return nil
+	}
+	// round trip through JSON too, so unknown keys are reported just like they are for the other formats
+	table := map[string]interface{}{}
+	if err := value.Decode(&table); err != nil {
+		return err
+	}
+	b, err := json.Marshal(table)
+	if err != nil {
+		return err
+	}
+	return d.fromTable(b)
+}
@@ -0,0 +1,111 @@
+package manager
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/morganhein/envy/pkg/io"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDownloadVerification(t *testing.T) {
+	contents := []byte("#!/bin/sh\necho hello\n")
+	sum := sha256.Sum256(contents)
+	checksums := hex.EncodeToString(sum[:]) + "  hello.sh\n"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/hello.sh":
+			w.Header().Set("Content-Disposition", `attachment; filename="hello.sh"`)
+			_, _ = w.Write(contents)
+		case "/v1/SHA256SUMS":
+			_, _ = w.Write([]byte(checksums))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	m := New(io.NewFilesystem(), &io.ShellMock{})
+
+	t.Run("checksums file", func(t *testing.T) {
+		to := t.TempDir()
+		filename, err := m.downloadHelper(context.Background(), RunConfig{}, Downloads{
+			From:      server.URL + "/v1/hello.sh",
+			To:        to,
+			Checksums: server.URL + "/v1/SHA256SUMS",
+		})
+		assert.NoError(t, err)
+		body, err := os.ReadFile(filename)
+		assert.NoError(t, err)
+		assert.Equal(t, contents, body)
+	})
+
+	t.Run("a mismatch leaves no file behind", func(t *testing.T) {
+		to := t.TempDir()
+		_, err := m.downloadHelper(context.Background(), RunConfig{}, Downloads{
+			From:   server.URL + "/v1/hello.sh",
+			To:     to,
+			SHA256: "0000000000000000000000000000000000000000000000000000000000000000",
+		})
+		assert.Error(t, err)
+		entries, err := os.ReadDir(to)
+		assert.NoError(t, err)
+		assert.Empty(t, entries)
+	})
+}
+
+func TestDownloadLocalSources(t *testing.T) {
+	recipeDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(recipeDir, "vendor"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(recipeDir, "vendor", "tool-linux-x86_64.sh"), []byte("tool"), 0644))
+	target := t.TempDir()
+	m := New(io.NewFilesystem(), &io.ShellMock{})
+	config := RunConfig{
+		RecipeLocation: filepath.Join(recipeDir, "envy.toml"),
+		Recipe: Recipe{
+			Tasks: map[string]Task{
+				"tool": {Download: []Downloads{
+					// a relative path, with the arch mapped, into an explicit filename
+					{From: "vendor/tool-${os}-${arch}.sh", To: target + "/bin/tool", Arch: map[string]string{"amd64": "x86_64"}},
+					// a file url into a directory
+					{From: "file://" + recipeDir + "/vendor/tool-linux-x86_64.sh", To: target + "/share/"},
+				}},
+			},
+		},
+	}
+	vars := envVariables{FACT_OS: "linux", FACT_ARCH: "amd64"}
+
+	err := m.runTaskHelper(context.Background(), config, vars, "tool")
+	require.NoError(t, err)
+	body, err := os.ReadFile(filepath.Join(target, "bin", "tool"))
+	assert.NoError(t, err)
+	assert.Equal(t, "tool", string(body))
+	_, err = os.Stat(filepath.Join(target, "share", "tool-linux-x86_64.sh"))
+	assert.NoError(t, err)
+}
+
+// the downloads of a task run in parallel, so run with -race to check they share the downloader safely
+func TestDownloadsRunInParallel(t *testing.T) {
+	source, target := t.TempDir(), t.TempDir()
+	var downloads []Downloads
+	for _, name := range []string{"a", "b", "c", "d"} {
+		require.NoError(t, os.WriteFile(filepath.Join(source, name), []byte(name), 0644))
+		downloads = append(downloads, Downloads{From: filepath.Join(source, name), To: target + "/"})
+	}
+	m := New(io.NewFilesystem(), &io.ShellMock{})
+	config := RunConfig{Recipe: Recipe{Tasks: map[string]Task{"files": {Download: downloads}}}}
+
+	err := m.runTaskHelper(context.Background(), config, envVariables{}, "files")
+	require.NoError(t, err)
+	for _, name := range []string{"a", "b", "c", "d"} {
+		body, err := os.ReadFile(filepath.Join(target, name))
+		assert.NoError(t, err)
+		assert.Equal(t, name, string(body))
+	}
+}
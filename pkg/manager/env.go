@@ -3,7 +3,10 @@ package manager
 import (
 	"context"
 	"github.com/morganhein/envy/pkg/io"
+	"os"
 	"path"
+	"sort"
+	"strings"
 
 	"golang.org/x/xerrors"
 )
@@ -14,6 +17,10 @@ const (
 	SYNC    Operation = "sync"
 	INSTALL Operation = "install"
 	TASK    Operation = "task"
+	APPLY   Operation = "apply"
+	REMOVE  Operation = "remove"
+	UNDO    Operation = "undo"
+	UPGRADE Operation = "upgrade"
 )
 
 const (
@@ -29,22 +36,24 @@ const (
 // determineBestAvailableInstaller determines installer based on following precedence:
 // 1. Installer specified by command line
 // 2. Package has a preferred installer method
-// 3. First available installer that is supported by the pkg
+// 3. First available installer that the pkg has a package name for
+// 4. First available installer that is supported by the pkg
+// Explicit installers, such as language package managers, are only used for packages that name them.
 func determineBestAvailableInstaller(ctx context.Context, config RunConfig, pkg Package, d Decider) (*Installer, error) {
 	availableInstallers := determineAvailableInstallers(ctx, config.Recipe.InstallerDefs, d)
 	io.PrintVerboseF(config.Verbose, "available installers: %+v", availableInstallers)
 	//if execution arguments have forced a specific installer to be used
 	if config.ForceInstaller != "" {
 		i, ok := config.Recipe.InstallerDefs[config.ForceInstaller]
+		i.Name = config.ForceInstaller
 		if ok && isAvailableInstaller(i, availableInstallers) {
-			i.Name = config.ForceInstaller
 			io.PrintVerboseF(config.Verbose, "user supplied installer chosen: %v", i.Name)
 			return &i, nil
 		}
 		return nil, xerrors.Errorf("an installer was requested (%v), but was not found", config.ForceInstaller)
 	}
 	// if preferred installer is available, use it
-	if requiredInstaller, ok := pkg["prefer"]; ok {
+	if requiredInstaller, ok := pkg[PKG_PREFER]; ok {
 		i, ok := config.Recipe.InstallerDefs[requiredInstaller]
 		if ok {
 			i.Name = requiredInstaller
@@ -53,20 +62,38 @@ func determineBestAvailableInstaller(ctx context.Context, config RunConfig, pkg
 		}
 		return nil, xerrors.Errorf("an installer was requested (%v), but was not found", requiredInstaller)
 	}
+	var candidates []Installer
+	for _, installer := range availableInstallers {
+		if _, named := pkg[installer.Name]; installer.Explicit && !named {
+			continue
+		}
+		candidates = append(candidates, installer)
+	}
 	if len(config.Recipe.General.InstallerPreferences) > 0 {
+		var preferred []Installer
 		for _, v := range config.Recipe.General.InstallerPreferences {
-			for _, availableInstaller := range availableInstallers {
-				if v == availableInstaller.Name {
-					io.PrintVerboseF(config.Verbose, "first available generally preferred installer chosen: %v", availableInstaller.Name)
-					return &availableInstaller, nil
+			for _, candidate := range candidates {
+				if v == candidate.Name {
+					preferred = append(preferred, candidate)
 				}
 			}
 		}
-		return nil, xerrors.Errorf("preferred installer(fs) are not available (%+v)", config.Recipe.General.InstallerPreferences)
+		if len(preferred) == 0 {
+			return nil, xerrors.Errorf("preferred installer(fs) are not available (%+v)", config.Recipe.General.InstallerPreferences)
+		}
+		candidates = preferred
+	}
+
+	// an installer the package has a name for is a better match than one that guesses the name
+	for _, installer := range candidates {
+		if _, named := pkg[installer.Name]; named {
+			io.PrintVerboseF(config.Verbose, "first available installer with a package name chosen: %v", installer.Name)
+			return &installer, nil
+		}
 	}
 
 	//no installer preferred, grab the first available one
-	for _, installer := range availableInstallers {
+	for _, installer := range candidates {
 		io.PrintVerboseF(config.Verbose, "first available installer chosen: %v", installer.Name)
 		return &installer, nil
 	}
@@ -74,16 +101,20 @@ func determineBestAvailableInstaller(ctx context.Context, config RunConfig, pkg
 	return nil, xerrors.New("unable to find a suitable installer")
 }
 
+// determineAvailableInstallers returns the installers that can run on this machine, sorted by name
 func determineAvailableInstallers(ctx context.Context, definedInstallers map[string]Installer, d Decider) []Installer {
 	var availableInstallers []Installer
 	for installerName, installer := range definedInstallers {
-		sr := d.ShouldRun(ctx, []string{}, installer.RunIf)
+		sr := d.ShouldRun(ctx, installer.SkipIf, installer.RunIf)
 		if !sr {
 			continue
 		}
 		installer.Name = installerName
 		availableInstallers = append(availableInstallers, installer)
 	}
+	sort.Slice(availableInstallers, func(i, j int) bool {
+		return availableInstallers[i].Name < availableInstallers[j].Name
+	})
 	return availableInstallers
 }
 
@@ -109,9 +140,69 @@ func (e envVariables) copy() envVariables {
 	return newEnv
 }
 
+// lookup finds the value of a variable, first in the envy variables, and then in the process environment
+func (e envVariables) lookup(name string) (string, bool) {
+	for _, k := range []string{name, strings.ToUpper(name), strings.ToLower(name)} {
+		if v, ok := e[k]; ok {
+			return v, true
+		}
+	}
+	return os.LookupEnv(name)
+}
+
+// resolveVars expands the variable definitions and adds them to env. Definitions can reference
+// facts, variables already in env, environment variables, and each other.
+func resolveVars(defs map[string]string, env envVariables) error {
+	resolved := map[string]bool{}
+	resolving := map[string]bool{}
+	var cycle error
+	var resolve func(name string) error
+	lookup := func(name string) (string, bool) {
+		if _, ok := defs[name]; ok && !resolved[name] {
+			if err := resolve(name); err != nil {
+				if cycle == nil {
+					cycle = err
+				}
+				return "", false
+			}
+		}
+		return env.lookup(name)
+	}
+	resolve = func(name string) error {
+		if resolving[name] {
+			return xerrors.Errorf("variable `%v` references itself", name)
+		}
+		resolving[name] = true
+		v, err := expandVars(defs[name], lookup)
+		if cycle != nil {
+			return cycle
+		}
+		if err != nil {
+			return xerrors.Errorf("error resolving variable `%v`: %w", name, err)
+		}
+		env[name] = v
+		resolved[name] = true
+		return nil
+	}
+	for name := range defs {
+		if resolved[name] {
+			continue
+		}
+		if err := resolve(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // set default environment variables
-func hydrateEnvironment(config RunConfig, env envVariables) {
+func hydrateEnvironment(config RunConfig, env envVariables) error {
+	for k, v := range config.facts {
+		env[k] = v
+	}
 	env[ORIGINAL_TASK] = config.originalTask
 	env[CONFIG_PATH] = path.Dir(config.RecipeLocation)
-	//possibly add link src and dst links here
+	env[SOURCE_PATH] = config.SourceDir
+	env[TARGET_PATH] = config.TargetDir
+	return resolveVars(config.Recipe.Vars, env)
 }
@@ -1 +1,76 @@
 package manager
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/morganhein/envy/pkg/io"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolveVars(t *testing.T) {
+	t.Run("variables can reference facts and each other", func(t *testing.T) {
+		env := envVariables{FACT_OS: "linux", FACT_ARCH: "amd64"}
+		err := resolveVars(map[string]string{
+			"bin":      "${prefix}/bin",
+			"prefix":   "/opt/${os}-${arch}",
+			"fallback": "${UNSET_ENVY_VAR:-${bin}}",
+		}, env)
+		assert.NoError(t, err)
+		assert.Equal(t, "/opt/linux-amd64", env["prefix"])
+		assert.Equal(t, "/opt/linux-amd64/bin", env["bin"])
+		assert.Equal(t, "/opt/linux-amd64/bin", env["fallback"])
+	})
+
+	t.Run("cycles are an error", func(t *testing.T) {
+		err := resolveVars(map[string]string{
+			"a": "${b}",
+			"b": "${a}",
+		}, envVariables{})
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "references itself")
+	})
+
+	t.Run("undefined references are an error", func(t *testing.T) {
+		err := resolveVars(map[string]string{"a": "${UNSET_ENVY_VAR}"}, envVariables{})
+		assert.ErrorAs(t, err, &UndefinedVariableError{})
+	})
+}
+
+func TestDetermineBestAvailableInstaller(t *testing.T) {
+	data, err := os.ReadFile("../../configs/default.toml")
+	assert.NoError(t, err)
+	recipe, err := DecodeRecipe(TOML, data)
+	assert.NoError(t, err)
+	available := map[string]bool{"which apt": true, "which apt-get": true, "which cargo": true, "which npm": true}
+	d := NewDecider(&io.ShellMock{
+		RunFunc: func(ctx context.Context, printOnly bool, cmdLine string) (string, error) {
+			if available[cmdLine] {
+				return "", nil
+			}
+			return "", errors.New("not found")
+		},
+	})
+	config := RunConfig{Recipe: *recipe}
+
+	t.Run("explicit installers are not used for packages that don't name them", func(t *testing.T) {
+		i, err := determineBestAvailableInstaller(context.Background(), config, Package{}, d)
+		assert.NoError(t, err)
+		assert.Equal(t, "apt", i.Name)
+	})
+
+	t.Run("an installer the package names is chosen", func(t *testing.T) {
+		i, err := determineBestAvailableInstaller(context.Background(), config, Package{"cargo": "ripgrep"}, d)
+		assert.NoError(t, err)
+		assert.Equal(t, "cargo", i.Name)
+		assert.False(t, i.Sudo)
+	})
+
+	t.Run("unavailable named installers fall back", func(t *testing.T) {
+		i, err := determineBestAvailableInstaller(context.Background(), config, Package{"gem": "rake"}, d)
+		assert.NoError(t, err)
+		assert.Equal(t, "apt", i.Name)
+	})
+}
@@ -0,0 +1,43 @@
+package manager
+
+import (
+	"os"
+	"runtime"
+	"strings"
+
+	"github.com/morganhein/envy/pkg/io"
+)
+
+// Facts are discovered about the machine envy is running on, and are available as variables
+const (
+	FACT_OS       = "os"
+	FACT_ARCH     = "arch"
+	FACT_HOSTNAME = "hostname"
+	FACT_DISTRO   = "distro"
+)
+
+func gatherFacts(fs io.Filesystem) envVariables {
+	facts := envVariables{
+		FACT_OS:   runtime.GOOS,
+		FACT_ARCH: runtime.GOARCH,
+	}
+	if hostname, err := os.Hostname(); err == nil {
+		facts[FACT_HOSTNAME] = hostname
+	}
+	facts[FACT_DISTRO] = detectDistro(fs)
+	return facts
+}
+
+// detectDistro returns the ID from os-release, such as "debian" or "alpine", or the os on systems without one
+func detectDistro(fs io.Filesystem) string {
+	f, err := fs.ReadFile("/etc/os-release")
+	if err != nil {
+		return runtime.GOOS
+	}
+	for _, line := range strings.Split(string(f), "\n") {
+		if strings.HasPrefix(line, "ID=") {
+			return strings.Trim(strings.TrimPrefix(line, "ID="), `"'`)
+		}
+	}
+	return runtime.GOOS
+}
@@ -0,0 +1,123 @@
+package manager
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"golang.org/x/xerrors"
+	"gopkg.in/yaml.v3"
+)
+
+// RecipeFormat is the encoding a recipe is stored in
+type RecipeFormat string
+
+const (
+	TOML RecipeFormat = "toml"
+	YAML RecipeFormat = "yaml"
+	JSON RecipeFormat = "json"
+)
+
+// recipeExtensions are the file extensions searched for in the default recipe locations, in load order
+var recipeExtensions = []string{".toml", ".yaml", ".yml", ".json"}
+
+// FormatFromPath determines the recipe format from the file extension of location. Recipes with any other
+// extension, or none, are TOML, as they were before there were other formats.
+func FormatFromPath(location string) RecipeFormat {
+	switch strings.ToLower(filepath.Ext(location)) {
+	case ".yaml", ".yml":
+		return YAML
+	case ".json":
+		return JSON
+	}
+	return TOML
+}
+
+// HasRecipeExtension checks if the file extension of location names a recipe format, rather than falling back to TOML
+func HasRecipeExtension(location string) bool {
+	ext := strings.ToLower(filepath.Ext(location))
+	for _, known := range recipeExtensions {
+		if ext == known {
+			return true
+		}
+	}
+	return false
+}
+
+// DecodeRecipe decodes a recipe from data. Every format decodes into the same structure,
+// and is validated the same way.
+func DecodeRecipe(format RecipeFormat, data []byte) (*Recipe, error) {
+	r := &Recipe{}
+	var err error
+	switch format {
+	case TOML:
+		_, err = toml.Decode(string(data), r)
+	case YAML:
+		err = yaml.Unmarshal(data, r)
+	case JSON:
+		err = json.Unmarshal(data, r)
+	default:
+		return nil, xerrors.Errorf("unsupported recipe format `%v`", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err = validateRecipe(r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// EncodeRecipe encodes the recipe in the requested format
+func EncodeRecipe(format RecipeFormat, r Recipe) ([]byte, error) {
+	switch format {
+	case TOML:
+		var buf bytes.Buffer
+		if err := toml.NewEncoder(&buf).Encode(r); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	case YAML:
+		return yaml.Marshal(r)
+	case JSON:
+		return json.MarshalIndent(r, "", "  ")
+	}
+	return nil, xerrors.Errorf("unsupported recipe format `%v`", format)
+}
+
+// validateRecipe checks the parts of a recipe that cannot be enforced by decoding alone
+func validateRecipe(r *Recipe) error {
+	for taskName, task := range r.Tasks {
+		if err := validateDownloads("task `"+taskName+"`", task.Download); err != nil {
+			return err
+		}
+	}
+	for shellName, shell := range r.Shells {
+		if err := validateDownloads("shell `"+shellName+"`", shell.Download); err != nil {
+			return err
+		}
+	}
+	for installerName, installer := range r.InstallerDefs {
+		switch installer.Type {
+		case "", RELEASE_INSTALLER:
+		default:
+			return xerrors.Errorf("installer `%v`: unknown installer type `%v`", installerName, installer.Type)
+		}
+	}
+	return nil
+}
+
+// validateDownloads checks the downloads of a task or shell, which is named by owner in the errors
+func validateDownloads(owner string, downloads []Downloads) error {
+	for _, dl := range downloads {
+		if len(dl.From) == 0 || len(dl.To) == 0 {
+			return xerrors.Errorf("%v: the download command must contain two parameters, the source and the target", owner)
+		}
+		if (len(dl.Signature) > 0) != (len(dl.PublicKey) > 0) {
+			return xerrors.Errorf("%v: verifying the signature of `%v` needs both the signature and the public_key", owner, dl.From)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/morganhein/envy/pkg/io"
+	"github.com/morganhein/envy/pkg/sync"
+	"golang.org/x/xerrors"
+)
+
+// defaultLinkDirs sets the source and target of links that weren't given, to those of the [sync] section. Without a
+// source there, links are relative to the recipe.
+func defaultLinkDirs(config *RunConfig) error {
+	settings, err := SyncSettings(config.Recipe)
+	if err != nil {
+		return err
+	}
+	if len(config.SourceDir) == 0 {
+		config.SourceDir = settings.Source
+	}
+	if len(config.SourceDir) == 0 {
+		config.SourceDir = path.Dir(config.RecipeLocation)
+	}
+	if len(config.TargetDir) == 0 {
+		config.TargetDir = settings.Target
+	}
+	return nil
+}
+
+// parseLink parses a link step, `from [to]`, where from is relative to the source and to is relative to the target.
+// Without to, the link has the same path in the target as in the source.
+func parseLink(config RunConfig, link string) (sync.Link, error) {
+	parts := strings.Fields(link)
+	if len(parts) == 0 || len(parts) > 2 {
+		return sync.Link{}, xerrors.Errorf("unexpected link format `%v`, which is `from [to]`", link)
+	}
+	to := parts[0]
+	if len(parts) == 2 {
+		to = parts[1]
+	}
+	resolve := func(base, p string) string {
+		if path.IsAbs(p) {
+			return path.Clean(p)
+		}
+		return path.Join(base, p)
+	}
+	return sync.Link{From: resolve(config.SourceDir, parts[0]), To: resolve(config.TargetDir, to)}, nil
+}
+
+// linkAllHelper links the files of a task, resolving any conflicts like `envy sync` does
+func (m *manager) linkAllHelper(ctx context.Context, config RunConfig, vars envVariables, links []string) error {
+	if len(links) == 0 {
+		return nil
+	}
+	sudo := determineSudo(config, nil)
+	links, err := injectAllStepVars(config, vars, "link", links, sudo)
+	if err != nil {
+		return err
+	}
+	var toLink []sync.Link
+	for _, link := range links {
+		l, err := parseLink(config, link)
+		if err != nil {
+			return err
+		}
+		io.PrintVerbose(config.Verbose, fmt.Sprintf("linking %v to %v", l.From, l.To), nil)
+		toLink = append(toLink, l)
+	}
+	action, err := sync.ParseConflictAction(config.Recipe.Sync.OnConflict)
+	if err != nil {
+		return err
+	}
+	return sync.CreateLinks(sync.LinkConfig{
+		Source:     config.SourceDir,
+		Target:     config.TargetDir,
+		Links:      toLink,
+		DryRun:     config.DryRun,
+		OnConflict: action,
+	})
+}
@@ -0,0 +1,48 @@
+package manager
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/morganhein/envy/pkg/io"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLinkStep(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	source, target := t.TempDir(), t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(source, "nvim"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(source, "nvim", "init.lua"), []byte("source"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(source, ".zshrc"), []byte("source"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(target, ".zshrc"), []byte("target"), 0644))
+
+	m := New(io.NewFilesystem(), &io.ShellMock{})
+	config := RunConfig{
+		Sudo:      "false",
+		SourceDir: source,
+		TargetDir: target,
+		Recipe:    Recipe{Sync: Sync{OnConflict: "backup"}},
+	}
+	vars := envVariables{}
+	require.NoError(t, hydrateEnvironment(config, vars))
+	err := m.linkAllHelper(context.Background(), config, vars, []string{"nvim ${TARGET_PATH}/.config/nvim", ".zshrc"})
+	require.NoError(t, err)
+
+	fs := io.NewFilesystem()
+	linked, err := fs.IsSymlinkTo(filepath.Join(target, ".config", "nvim"), filepath.Join(source, "nvim"))
+	assert.NoError(t, err)
+	assert.True(t, linked)
+	// the file that was in the way is backed up
+	linked, err = fs.IsSymlinkTo(filepath.Join(target, ".zshrc"), filepath.Join(source, ".zshrc"))
+	assert.NoError(t, err)
+	assert.True(t, linked)
+	backups, err := filepath.Glob(filepath.Join(os.Getenv("XDG_STATE_HOME"), "envy", "backups", "*", ".zshrc"))
+	assert.NoError(t, err)
+	assert.Len(t, backups, 1)
+
+	_, err = parseLink(config, "a b c")
+	assert.Error(t, err)
+}
@@ -0,0 +1,128 @@
+package manager
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/morganhein/envy/pkg/io"
+	"github.com/morganhein/envy/pkg/sync"
+)
+
+// Lint checks a recipe without running anything. Every task step and installer command is expanded in
+// strict mode, so that any reference to an undefined variable is reported. The variables of the profiles count as
+// defined, since the tasks run with them when a profile is applied.
+func Lint(fs io.Filesystem, config RunConfig) []error {
+	recipe, err := ResolveRecipe(fs, config.RecipeLocation)
+	if err != nil {
+		return []error{err}
+	}
+	config.Recipe = *recipe
+	config.Recipe.Vars = withProfileVars(config.Recipe)
+	config.facts = gatherFacts(fs)
+	config.Strict = true
+	vars := envVariables{}
+	if err := hydrateEnvironment(config, vars); err != nil {
+		return []error{err}
+	}
+
+	var errs []error
+	check := func(vars envVariables, step string, lines []string) {
+		for _, line := range lines {
+			if _, err := injectStepVars(config, vars, step, line, false); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	taskNames := make([]string, 0, len(config.Recipe.Tasks))
+	for name := range config.Recipe.Tasks {
+		taskNames = append(taskNames, name)
+	}
+	sort.Strings(taskNames)
+	for _, name := range taskNames {
+		t := config.Recipe.Tasks[name]
+		taskVars := vars.copy()
+		taskVars[CURRENT_TASK] = name
+		if err := resolveVars(t.Vars, taskVars); err != nil {
+			errs = append(errs, fmt.Errorf("task `%v`: %w", name, err))
+			continue
+		}
+		check(taskVars, "skip_if", t.SkipIf)
+		check(taskVars, "run_if", t.RunIf)
+		for _, dl := range t.Download {
+			check(taskVars, "download", []string{dl.From, dl.To, dl.Checksums, dl.Signature})
+		}
+		check(taskVars, "link", t.Link)
+		check(taskVars, "deps", t.Deps)
+		check(taskVars, "pre_cmd", t.PreCmds)
+		check(taskVars, "install", t.Install)
+		check(taskVars, "post_cmd", t.PostCmds)
+		if t.Teardown != nil {
+			check(taskVars, "teardown pre_cmd", t.Teardown.PreCmds)
+			check(taskVars, "teardown remove", t.Teardown.Remove)
+			check(taskVars, "teardown post_cmd", t.Teardown.PostCmds)
+		}
+	}
+
+	installerNames := make([]string, 0, len(config.Recipe.InstallerDefs))
+	for name := range config.Recipe.InstallerDefs {
+		installerNames = append(installerNames, name)
+	}
+	sort.Strings(installerNames)
+	for _, name := range installerNames {
+		i := config.Recipe.InstallerDefs[name]
+		// the package is only known at runtime
+		cmdLine := installCommandVariableSubstitution(i.Cmd, "pkg", false)
+		check(vars, fmt.Sprintf("installer `%v` cmd", name), []string{cmdLine})
+		check(vars, fmt.Sprintf("installer `%v` update", name), []string{i.Update})
+		check(vars, fmt.Sprintf("installer `%v` check", name), []string{installCommandVariableSubstitution(i.Check, "pkg", false)})
+		check(vars, fmt.Sprintf("installer `%v` remove", name), []string{installCommandVariableSubstitution(i.Remove, "pkg", false)})
+		check(vars, fmt.Sprintf("installer `%v` upgrade", name), []string{i.Upgrade})
+		check(vars, fmt.Sprintf("installer `%v` version", name), []string{installCommandVariableSubstitution(i.Version, "pkg", false)})
+		check(vars, fmt.Sprintf("installer `%v` upgrade_pkg", name), []string{installCommandVariableSubstitution(i.UpgradePkg, "pkg", false)})
+	}
+
+	releaseNames := make([]string, 0, len(config.Recipe.Releases))
+	for name := range config.Recipe.Releases {
+		releaseNames = append(releaseNames, name)
+	}
+	sort.Strings(releaseNames)
+	for _, name := range releaseNames {
+		r := config.Recipe.Releases[name]
+		// the version, os and arch are filled in when the release is installed
+		url := strings.NewReplacer("${version}", "version", "${os}", "os", "${arch}", "arch").Replace(r.URL)
+		check(vars, fmt.Sprintf("release `%v` url", name), []string{url})
+		check(vars, fmt.Sprintf("release `%v` bin_dir", name), []string{r.BinDir})
+	}
+
+	if _, err := sync.ParseSyncMode(config.Recipe.Sync.Mode); err != nil {
+		errs = append(errs, fmt.Errorf("sync mode: %w", err))
+	}
+	if _, err := sync.ParseConflictAction(config.Recipe.Sync.OnConflict); err != nil {
+		errs = append(errs, fmt.Errorf("sync on_conflict: %w", err))
+	}
+	return errs
+}
+
+// withProfileVars returns the variables of the recipe, along with the variables of every profile that the recipe
+// doesn't define itself
+func withProfileVars(r Recipe) map[string]string {
+	vars := map[string]string{}
+	for k, v := range r.Vars {
+		vars[k] = v
+	}
+	profileNames := make([]string, 0, len(r.Profiles))
+	for name := range r.Profiles {
+		profileNames = append(profileNames, name)
+	}
+	sort.Strings(profileNames)
+	for _, name := range profileNames {
+		for k, v := range r.Profiles[name].Vars {
+			if _, ok := vars[k]; !ok {
+				vars[k] = v
+			}
+		}
+	}
+	return vars
+}
@@ -0,0 +1,35 @@
+package manager
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/morganhein/envy/pkg/io"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLint(t *testing.T) {
+	location := filepath.Join(t.TempDir(), "recipe.toml")
+	err := os.WriteFile(location, []byte(`
+[vars]
+    prefix = "/opt/envy"
+
+[task.cleanup]
+    pre_cmd = ["echo ${prefix:-/usr}", "echo $${ESCAPED}", "echo ${work_dir}"]
+    post_cmd = ["rm -rf ${UNSET_ENVY_PREFIX}/bin"]
+
+[profile.work]
+    tasks = ["cleanup"]
+    vars = { work_dir = "${prefix}/work" }
+
+[sync]
+    mode = "hardlink"
+`), 0644)
+	assert.NoError(t, err)
+
+	errs := Lint(io.NewFilesystem(), RunConfig{RecipeLocation: location})
+	assert.Len(t, errs, 2)
+	assert.EqualError(t, errs[0], "task `cleanup` post_cmd: variable `UNSET_ENVY_PREFIX` is not defined")
+	assert.EqualError(t, errs[1], "sync mode: unknown sync mode `hardlink`, expected link or copy")
+}
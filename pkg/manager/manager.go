@@ -6,8 +6,11 @@ import (
 	"fmt"
 	"github.com/spf13/cobra"
 	"golang.org/x/xerrors"
+	"net/url"
+	"os"
 	"path"
 	"strings"
+	"sync"
 
 	"github.com/morganhein/envy/pkg/io"
 )
@@ -23,19 +26,25 @@ type RunConfig struct {
 	RecipeLocation string
 	Operation      Operation
 	Recipe         Recipe
-	ForceInstaller string // ForceInstaller will try to force the specified installer
-	Sudo           string // Sudo will force using sudo when performing commands
-	Verbose        bool   // Talk more
-	DryRun         bool   // Don't actually run installation/copy/symlink commands
-	TargetDir      string // TargetDir is the base directory for symlinks, defaults to ${HOME}
-	SourceDir      string // SourceDir is the base directory to search for source files to symlink against, defaults to dir(RecipeLocation)
-	originalTask   string // used for environment variable replacement. Do we need?
+	ForceInstaller string              // ForceInstaller will try to force the specified installer
+	Profile        string              // Profile will force the specified profile when applying
+	Sudo           string              // Sudo will force using sudo when performing commands
+	Verbose        bool                // Talk more
+	DryRun         bool                // Don't actually run installation/copy/symlink commands
+	Strict         bool                // Refuse to run commands that reference undefined variables
+	Offline        bool                // Only use downloads that are already in the download cache
+	Progress       io.ProgressObserver // Progress receives the progress of downloads, if set
+	TargetDir      string              // TargetDir is the base directory for symlinks, defaults to ${HOME}
+	SourceDir      string              // SourceDir is the base directory to search for source files to symlink against, defaults to dir(RecipeLocation)
+	originalTask   string              // used for environment variable replacement. Do we need?
+	facts          envVariables
 }
 
 type manager struct {
 	d                 Decider
 	r                 io.Shell
-	dl                io.Downloader //not being used yet due to refactor
+	dl                io.Downloader
+	dlOnce            sync.Once // the downloads of a task run in parallel, so the downloader is set up once
 	fs                io.Filesystem
 	updatedInstallers map[string]interface{}
 }
@@ -46,6 +55,8 @@ func New(fs io.Filesystem, shell io.Shell) manager {
 		d:  d,
 		r:  shell,
 		fs: fs,
+
+		updatedInstallers: make(map[string]interface{}),
 	}
 }
 
@@ -59,6 +70,18 @@ func (m *manager) Start(ctx context.Context, config RunConfig, name string) erro
 		cobra.CheckErr(err)
 	}
 	config.Recipe = *tConfig
+	config.facts = gatherFacts(m.fs)
+	if err = defaultLinkDirs(&config); err != nil {
+		return err
+	}
+	// downloads from the command line are kept in the cache, so later runs don't need to fetch them again
+	if m.dl == nil {
+		cacheDir, err := io.DefaultCacheDir()
+		if err != nil {
+			return err
+		}
+		m.dl = io.NewCachedDownloader(io.NewDownloadCache(cacheDir), config.Offline, config.Verbose, config.Progress)
+	}
 	io.PrintVerboseF(config.Verbose, "Operation: %v, Name: %v, verbose: %v, sudo: %v",
 		config.Operation,
 		name,
@@ -71,27 +94,73 @@ func (m *manager) Start(ctx context.Context, config RunConfig, name string) erro
 	if config.Operation == INSTALL {
 		return m.RunInstall(ctx, config, name)
 	}
+	if config.Operation == APPLY {
+		return m.RunApply(ctx, config)
+	}
+	if config.Operation == REMOVE {
+		return m.RunRemove(ctx, config, name)
+	}
+	if config.Operation == UPGRADE {
+		return m.RunUpgrade(ctx, config, name)
+	}
+	if config.Operation == UNDO {
+		config.originalTask = name
+		return m.RunUndo(ctx, config, name)
+	}
 	return xerrors.Errorf("Operation `%v` not supported", config.Operation)
 }
 
 func (m *manager) RunTask(ctx context.Context, config RunConfig, task string) error {
 	//start tracking environment variables
 	vars := envVariables{}
-	hydrateEnvironment(config, vars)
+	if err := hydrateEnvironment(config, vars); err != nil {
+		return err
+	}
 	io.PrintVerbose(config.Verbose, fmt.Sprintf("original environment variables: %+v", vars), nil)
 	return m.runTaskHelper(ctx, config, vars, task)
 }
 
+// RunApply runs every task in the profile selected for this machine
+func (m *manager) RunApply(ctx context.Context, config RunConfig) error {
+	name, profile, err := selectProfile(config)
+	if err != nil {
+		return err
+	}
+	io.PrintVerboseF(config.Verbose, "applying profile `%v`", name)
+	//profile variables override the recipe variables before they are resolved, so other variables can use them
+	recipeVars := map[string]string{}
+	for k, v := range config.Recipe.Vars {
+		recipeVars[k] = v
+	}
+	for k, v := range profile.Vars {
+		recipeVars[k] = v
+	}
+	config.Recipe.Vars = recipeVars
+	for _, task := range profile.Tasks {
+		config.originalTask = task
+		if err := m.RunTask(ctx, config, task); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (m *manager) RunInstall(ctx context.Context, config RunConfig, pkg string) error {
 	//start tracking environment variables
 	vars := envVariables{}
-	hydrateEnvironment(config, vars)
+	if err := hydrateEnvironment(config, vars); err != nil {
+		return err
+	}
 	io.PrintVerbose(config.Verbose, fmt.Sprintf("original environment variables: %+v", vars), nil)
 	//this should go straight to the pkg install helper, and none of this other business
 	return m.installPkgHelper(ctx, config, vars, pkg)
 }
 
 func (m *manager) handleDependency(ctx context.Context, config RunConfig, vars envVariables, taskOrPkg string) error {
+	taskOrPkg, err := injectStepVars(config, vars, "deps", taskOrPkg, determineSudo(config, nil))
+	if err != nil {
+		return err
+	}
 	if len(taskOrPkg) == 0 {
 		return xerrors.New("task or package is empty")
 	}
@@ -121,20 +190,55 @@ func (m *manager) runTaskHelper(ctx context.Context, config RunConfig, vars envV
 		return xerrors.Errorf("task '%v' not defined in config", task)
 	}
 
-	if sr := m.d.ShouldRun(ctx, t.SkipIf, t.RunIf); !sr {
+	//task variables are only visible to this task
+	vars = vars.copy()
+	vars[CURRENT_TASK] = task
+	if err := resolveVars(t.Vars, vars); err != nil {
+		return xerrors.Errorf("task '%v': %w", task, err)
+	}
+	sudo := determineSudo(config, nil)
+
+	skipIf, err := injectAllStepVars(config, vars, "skip_if", t.SkipIf, sudo)
+	if err != nil {
+		return err
+	}
+	runIf, err := injectAllStepVars(config, vars, "run_if", t.RunIf, sudo)
+	if err != nil {
+		return err
+	}
+	if sr := m.d.ShouldRun(ctx, skipIf, runIf); !sr {
 		io.PrintVerbose(config.Verbose, fmt.Sprintf("task '%v' failed skip_if or run_if check", task), nil)
 		return nil
 	}
 
-	//download the files
+	//download the files, all at once
+	var downloads []Downloads
 	for _, dlReq := range t.Download {
-		if len(dlReq) != 2 {
+		if len(dlReq.From) == 0 || len(dlReq.To) == 0 {
 			return xerrors.New("the download command must contain two parameters, the source and the target")
 		}
-		_, err := m.dl.Download(ctx, dlReq[0], dlReq[1])
+		// the urls see the os and arch by the names the download uses
+		urlVars := dlReq.urlVars(vars)
+		parts, err := injectAllStepVars(config, urlVars, "download", []string{dlReq.From, dlReq.Checksums, dlReq.Signature}, sudo)
 		if err != nil {
 			return err
 		}
+		dlReq.From, dlReq.Checksums, dlReq.Signature = parts[0], parts[1], parts[2]
+		if dlReq.To, err = injectStepVars(config, vars, "download", dlReq.To, sudo); err != nil {
+			return err
+		}
+		if dlReq.Include, err = injectAllStepVars(config, vars, "download include", dlReq.Include, sudo); err != nil {
+			return err
+		}
+		downloads = append(downloads, dlReq)
+	}
+	if err := m.downloadAllHelper(ctx, config, downloads); err != nil {
+		return err
+	}
+
+	//link the files from the source into the target, all at once
+	if err := m.linkAllHelper(ctx, config, vars, t.Link); err != nil {
+		return err
 	}
 
 	//run the deps
@@ -146,21 +250,23 @@ func (m *manager) runTaskHelper(ctx context.Context, config RunConfig, vars envV
 
 	//run the pre-cmds
 	for _, cmd := range t.PreCmds {
-		if err := m.runCmdHelper(ctx, config, vars, cmd); err != nil {
+		if err := m.runCmdHelper(ctx, config, vars, "pre_cmd", cmd); err != nil {
 			return err
 		}
 	}
 
 	//install the packages
-	for _, pkg := range t.Install {
-		if err := m.RunInstall(ctx, config, pkg); err != nil {
-			return err
-		}
+	pkgs, err := injectAllStepVars(config, vars, "install", t.Install, sudo)
+	if err != nil {
+		return err
+	}
+	if err := m.installPkgsHelper(ctx, config, vars, pkgs); err != nil {
+		return err
 	}
 
 	//run the post-cmds
 	for _, cmd := range t.PostCmds {
-		if err := m.runCmdHelper(ctx, config, vars, cmd); err != nil {
+		if err := m.runCmdHelper(ctx, config, vars, "post_cmd", cmd); err != nil {
 			return err
 		}
 	}
@@ -169,11 +275,14 @@ func (m *manager) runTaskHelper(ctx context.Context, config RunConfig, vars envV
 }
 
 // runCmdHelper runs any commands in pre/post cmds with variables replaced
-func (m *manager) runCmdHelper(ctx context.Context, config RunConfig, vars envVariables, cmdLine string) error {
+func (m *manager) runCmdHelper(ctx context.Context, config RunConfig, vars envVariables, step, cmdLine string) error {
 	//cleanup first
 	cmdLine = strings.TrimSpace(cmdLine)
 	sudo := determineSudo(config, nil)
-	cmdLine = injectVars(cmdLine, vars, sudo)
+	cmdLine, err := injectStepVars(config, vars, step, cmdLine, sudo)
+	if err != nil {
+		return err
+	}
 	io.PrintVerbose(config.Verbose, fmt.Sprintf("running command `%v`", cmdLine), nil)
 	out, err := m.r.Run(ctx, config.DryRun, cmdLine)
 	io.PrintVerbose(config.Verbose, out, err)
@@ -183,42 +292,160 @@ func (m *manager) runCmdHelper(ctx context.Context, config RunConfig, vars envVa
 	return nil
 }
 
-func (m *manager) downloadHelper(ctx context.Context, dl Downloads) (string, error) {
-	if len(dl) == 2 {
-		return m.dl.Download(ctx, dl[0], dl[1])
+// downloadAllHelper runs the downloads in parallel, and returns the first error
+func (m *manager) downloadAllHelper(ctx context.Context, config RunConfig, downloads []Downloads) error {
+	errs := make([]error, len(downloads))
+	var wg sync.WaitGroup
+	for i, dl := range downloads {
+		wg.Add(1)
+		go func(i int, dl Downloads) {
+			defer wg.Done()
+			_, errs[i] = m.downloadHelper(ctx, config, dl)
+		}(i, dl)
 	}
-	return "", errors.New("incorrect syntax for a download command")
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-// TODO (@morgan): this should probably be removed? in lieu of the sync operation?
-func (m *manager) symlinkHelper(ctx context.Context, config RunConfig, vars envVariables, link string) error {
-	io.PrintVerbose(config.Verbose, fmt.Sprintf("creating symlink `%v`", link), nil)
-	parts := strings.Split(link, " ")
-	if len(parts) > 2 {
-		return xerrors.New("unexpected symlink format, which is `from [to]`")
+// downloadHelper downloads the file into the target location. Archives that are extracted are downloaded to a
+// temporary directory first, and their contents are extracted into the target directory.
+func (m *manager) downloadHelper(ctx context.Context, config RunConfig, dl Downloads) (string, error) {
+	if len(dl.From) == 0 || len(dl.To) == 0 {
+		return "", errors.New("incorrect syntax for a download command")
 	}
-	from := path.Join(config.SourceDir, parts[0])
-	to := path.Join(config.TargetDir, parts[0])
-	if len(parts) == 2 {
-		to = path.Join(config.TargetDir, parts[1])
+	dl.From = localSourceHelper(config, dl.From)
+	dl.Checksums = localSourceHelper(config, dl.Checksums)
+	dl.Signature = localSourceHelper(config, dl.Signature)
+	if config.DryRun {
+		fmt.Printf("downloading %v to %v\n", dl.From, dl.To)
+		return dl.To, nil
+	}
+	tmp, err := os.MkdirTemp("", "envy-download-")
+	if err != nil {
+		return "", xerrors.Errorf("error creating a download directory: %v", err)
+	}
+	defer func() {
+		_ = os.RemoveAll(tmp)
+	}()
+	opts, err := m.verifyOptionsHelper(ctx, config, dl, tmp)
+	if err != nil {
+		return "", err
 	}
+	if !dl.Extract {
+		// a target that isn't a directory is the name of the file
+		if info, err := m.fs.Stat(dl.To); !strings.HasSuffix(dl.To, "/") && (err != nil || !info.IsDir()) {
+			return m.downloader().Download(ctx, dl.From, path.Dir(dl.To), append(opts, io.WithFilename(path.Base(dl.To)))...)
+		}
+		return m.downloader().Download(ctx, dl.From, dl.To, opts...)
+	}
+	archive, err := m.downloader().Download(ctx, dl.From, tmp, opts...)
+	if err != nil {
+		return "", err
+	}
+	io.PrintVerboseF(config.Verbose, "extracting `%v` into `%v`", dl.From, dl.To)
+	err = io.ExtractArchive(archive, dl.To, io.ExtractOptions{
+		StripComponents: dl.StripComponents,
+		Include:         dl.Include,
+	})
+	if err != nil {
+		return "", xerrors.Errorf("error extracting `%v`: %w", dl.From, err)
+	}
+	return dl.To, nil
+}
 
-	if config.DryRun {
-		fmt.Printf("symlinking from %v to %v\n", from, to)
-		return nil
+// localSourceHelper resolves a relative source path against the directory of the recipe, so files can be kept
+// next to it. Urls and absolute paths are left as they are.
+func localSourceHelper(config RunConfig, from string) string {
+	if len(from) == 0 || path.IsAbs(from) || strings.Contains(from, "://") {
+		return from
 	}
+	return path.Join(path.Dir(config.RecipeLocation), from)
+}
 
-	out, err := func() (string, error) {
-		fmt.Println(link)
-		return "", nil
-	}()
-	io.PrintVerbose(config.Verbose, out, err)
-	return err
+// verifyOptionsHelper builds the checks a download has to pass before it is moved into place. Checksums files
+// and signatures are downloaded into tmp.
+func (m *manager) verifyOptionsHelper(ctx context.Context, config RunConfig, dl Downloads, tmp string) ([]io.DownloadOption, error) {
+	var opts []io.DownloadOption
+	if len(dl.SHA256) > 0 {
+		opts = append(opts, io.WithChecksum(io.SHA256, dl.SHA256))
+	}
+	if len(dl.SHA512) > 0 {
+		opts = append(opts, io.WithChecksum(io.SHA512, dl.SHA512))
+	}
+	if len(dl.Checksums) > 0 {
+		list, err := m.fetchHelper(ctx, dl.Checksums, tmp)
+		if err != nil {
+			return nil, xerrors.Errorf("error downloading the checksums of `%v`: %w", dl.From, err)
+		}
+		from, err := url.Parse(dl.From)
+		if err != nil {
+			return nil, xerrors.Errorf("error parsing url: %v", err)
+		}
+		algorithm, sum, err := io.ChecksumFromList(list, path.Base(from.Path))
+		if err != nil {
+			return nil, xerrors.Errorf("error finding the checksum of `%v`: %w", dl.From, err)
+		}
+		io.PrintVerboseF(config.Verbose, "expecting %v `%v` for `%v`", algorithm, sum, dl.From)
+		opts = append(opts, io.WithChecksum(algorithm, sum))
+	}
+	if len(dl.Signature) > 0 {
+		signature, err := m.fetchHelper(ctx, dl.Signature, tmp)
+		if err != nil {
+			return nil, xerrors.Errorf("error downloading the signature of `%v`: %w", dl.From, err)
+		}
+		opts = append(opts, io.WithVerifier(func(filename string) error {
+			return io.VerifySignature(filename, signature, dl.PublicKey)
+		}))
+	}
+	return opts, nil
+}
+
+// downloader is the downloader that was set, or one without a cache
+func (m *manager) downloader() io.Downloader {
+	m.dlOnce.Do(func() {
+		if m.dl == nil {
+			m.dl = io.NewDownloader()
+		}
+	})
+	return m.dl
+}
+
+// fetchHelper downloads a small file, such as a checksums file, into dir and returns its contents
+func (m *manager) fetchHelper(ctx context.Context, from, dir string) ([]byte, error) {
+	dir, err := os.MkdirTemp(dir, "fetch-")
+	if err != nil {
+		return nil, err
+	}
+	filename, err := m.downloader().Download(ctx, from, dir)
+	if err != nil {
+		return nil, err
+	}
+	return os.ReadFile(filename)
 }
 
 func (m *manager) installPkgHelper(ctx context.Context, config RunConfig, vars envVariables, pkgName string) error {
+	return m.installPkgsHelper(ctx, config, vars, []string{pkgName})
+}
+
+// resolvedPkg is a package name from the recipe, resolved against the installer that will install it
+type resolvedPkg struct {
+	name        string // the name used in the recipe
+	installName string // the name used by the installer
+	installSpec string // the name passed to the install command, which may include a pinned version
+	constraints []versionConstraint
+	installer   *Installer
+	sudo        bool
+}
+
+// resolvePkgHelper determines which installer will install the package, and the name that installer knows it by
+func (m *manager) resolvePkgHelper(ctx context.Context, config RunConfig, pkgName string) (*resolvedPkg, error) {
 	if len(pkgName) == 0 {
-		return errors.New("unable to find the package name")
+		return nil, errors.New("unable to find the package name")
 	}
 
 	//look up the package in the config, if it exists.
@@ -227,34 +454,138 @@ func (m *manager) installPkgHelper(ctx context.Context, config RunConfig, vars e
 	//determine which installer is preferred with this package
 	installer, err := determineBestAvailableInstaller(ctx, config, pkg, m.d)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	io.PrintVerboseF(config.Verbose, "resolved installer to `%v`", installer.Name)
 
-	//run the install commands for that installer
-	//do we sudo, or do we not?
-	sudo := determineSudo(config, installer)
+	//determine package name in relation to the chosen installer
+	newPkgName, ok := pkg[installer.Name]
+	if !ok {
+		newPkgName = pkgName
+	}
 
-	//insure installer has been updated, if possible
-	if _, ok := m.updatedInstallers[installer.Name]; !ok && len(installer.Update) > 0 {
-		cmdLine := replaceSudo(installer.Update, sudo)
-		io.PrintVerboseF(config.Verbose, "running update for installer `%v` for the first time", installer.Name)
-		_, err = m.r.Run(ctx, config.DryRun, cmdLine)
+	resolved := &resolvedPkg{
+		name:        pkgName,
+		installName: newPkgName,
+		installSpec: newPkgName,
+		installer:   installer,
+		//do we sudo, or do we not?
+		sudo: determineSudo(config, installer),
+	}
+	if constraint, ok := pkg[PKG_VERSION]; ok {
+		resolved.constraints, err = parseVersionConstraints(constraint)
+		if err != nil {
+			return nil, xerrors.Errorf("package `%v`: %w", pkgName, err)
+		}
+		if version, pinned := pinnedVersion(resolved.constraints); pinned && len(installer.VersionedPkg) > 0 {
+			resolved.installSpec = versionedPkgSubstitution(installer.VersionedPkg, newPkgName, version)
+		}
+	}
+	return resolved, nil
+}
+
+// installPkgsHelper installs the packages, skipping any that are already installed. Packages are grouped by
+// the installer that resolves them, and installers with a ${pkgs} command install each group with a single
+// invocation. If that fails, the packages in the group are installed one at a time to find the culprit.
+func (m *manager) installPkgsHelper(ctx context.Context, config RunConfig, vars envVariables, pkgNames []string) error {
+	var installerOrder []string
+	groups := map[string][]*resolvedPkg{}
+	for _, pkgName := range pkgNames {
+		pkg, err := m.resolvePkgHelper(ctx, config, pkgName)
+		if err != nil {
+			return err
+		}
+		if pkg.installer.Type == RELEASE_INSTALLER {
+			if err := m.installReleaseHelper(ctx, config, vars, pkg); err != nil {
+				return err
+			}
+			continue
+		}
+		if err = pkg.checkPin(); err != nil {
+			return err
+		}
+		installed, err := m.isInstalledHelper(ctx, config, vars, pkg.installer, pkg.installName, pkg.sudo)
 		if err != nil {
 			return err
 		}
-		m.updatedInstallers[installer.Name] = nil
+		if installed {
+			satisfied, version, err := m.versionSatisfiedHelper(ctx, config, vars, pkg)
+			if err != nil {
+				return err
+			}
+			if satisfied {
+				fmt.Printf("%v: ok\n", pkg.name)
+				continue
+			}
+			fmt.Printf("%v: installed version %v does not satisfy `%v`, reinstalling\n", pkg.name, version, pkg.constraintString())
+		}
+		if _, ok := groups[pkg.installer.Name]; !ok {
+			installerOrder = append(installerOrder, pkg.installer.Name)
+		}
+		groups[pkg.installer.Name] = append(groups[pkg.installer.Name], pkg)
 	}
 
-	//determine package name in relation to the chosen installer
-	newPkgName, ok := pkg[installer.Name]
-	if !ok {
-		newPkgName = pkgName
+	for _, installerName := range installerOrder {
+		group := groups[installerName]
+		installer := group[0].installer
+		sudo := group[0].sudo
+
+		//insure installer has been updated, if possible
+		if err := m.updateInstallerHelper(ctx, config, installer, sudo); err != nil {
+			return err
+		}
+
+		if len(group) > 1 && isBatchInstaller(installer) {
+			names := make([]string, 0, len(group))
+			for _, pkg := range group {
+				names = append(names, pkg.installSpec)
+			}
+			err := m.runInstallCmdHelper(ctx, config, vars, installer, sudo, names)
+			if err == nil {
+				err = m.verifyVersionsHelper(ctx, config, vars, group)
+				if err != nil {
+					return err
+				}
+				continue
+			}
+			io.PrintWarningF("installing %v with `%v` failed, installing them one at a time", names, installer.Name)
+		}
+
+		for _, pkg := range group {
+			//TODO (@morgan): at this point, if it is a shell installer, call that instead
+			if err := m.runInstallCmdHelper(ctx, config, vars, installer, sudo, []string{pkg.installSpec}); err != nil {
+				return xerrors.Errorf("error installing package `%v`: %w", pkg.name, err)
+			}
+			if err := m.verifyVersionsHelper(ctx, config, vars, []*resolvedPkg{pkg}); err != nil {
+				return err
+			}
+		}
 	}
+	return nil
+}
 
-	//TODO (@morgan): at this point, if it is a shell installer, call that instead
+// updateInstallerHelper runs the update command of the installer, the first time it is used
+func (m *manager) updateInstallerHelper(ctx context.Context, config RunConfig, installer *Installer, sudo bool) error {
+	if _, ok := m.updatedInstallers[installer.Name]; ok || len(installer.Update) == 0 {
+		return nil
+	}
+	cmdLine := replaceSudo(installer.Update, sudo)
+	io.PrintVerboseF(config.Verbose, "running update for installer `%v` for the first time", installer.Name)
+	_, err := m.r.Run(ctx, config.DryRun, cmdLine)
+	if err != nil {
+		return err
+	}
+	m.updatedInstallers[installer.Name] = nil
+	return nil
+}
 
-	cmdLine := installCommandVariableSubstitution(installer.Cmd, newPkgName, sudo)
+// runInstallCmdHelper runs the install command of the installer for the packages
+func (m *manager) runInstallCmdHelper(ctx context.Context, config RunConfig, vars envVariables, installer *Installer, sudo bool, pkgs []string) error {
+	cmdLine, err := injectStepVars(config, vars, fmt.Sprintf("installer `%v` cmd", installer.Name),
+		batchCommandVariableSubstitution(installer.Cmd, pkgs, sudo), sudo)
+	if err != nil {
+		return err
+	}
 	io.PrintVerboseF(config.Verbose, "running command `%v`", cmdLine)
 
 	out, err := m.r.Run(ctx, config.DryRun, cmdLine)
@@ -265,3 +596,76 @@ func (m *manager) installPkgHelper(ctx context.Context, config RunConfig, vars e
 	io.PrintVerboseF(config.Verbose, "package installation successful")
 	return nil
 }
+
+// isInstalledHelper runs the check command of the installer, if it has one, to determine if the package is already installed
+func (m *manager) isInstalledHelper(ctx context.Context, config RunConfig, vars envVariables, installer *Installer, pkgName string, sudo bool) (bool, error) {
+	if len(installer.Check) == 0 {
+		return false, nil
+	}
+	cmdLine, err := injectStepVars(config, vars, fmt.Sprintf("installer `%v` check", installer.Name),
+		installCommandVariableSubstitution(installer.Check, pkgName, sudo), sudo)
+	if err != nil {
+		return false, err
+	}
+	io.PrintVerboseF(config.Verbose, "checking if `%v` is installed with `%v`", pkgName, cmdLine)
+	//detection can never be a "dry run"
+	_, err = m.r.Run(ctx, false, cmdLine)
+	return err == nil, nil
+}
+
+// versionSatisfiedHelper queries the installed version of the package, and compares it to the version constraints
+// of the package. Packages without constraints, or installers that can't query versions, are always satisfied.
+func (m *manager) versionSatisfiedHelper(ctx context.Context, config RunConfig, vars envVariables, pkg *resolvedPkg) (bool, string, error) {
+	if len(pkg.constraints) == 0 || len(pkg.installer.Version) == 0 {
+		return true, "", nil
+	}
+	cmdLine, err := injectStepVars(config, vars, fmt.Sprintf("installer `%v` version", pkg.installer.Name),
+		installCommandVariableSubstitution(pkg.installer.Version, pkg.installName, pkg.sudo), pkg.sudo)
+	if err != nil {
+		return false, "", err
+	}
+	io.PrintVerboseF(config.Verbose, "querying the installed version of `%v` with `%v`", pkg.installName, cmdLine)
+	//detection can never be a "dry run"
+	out, err := m.r.Run(ctx, false, cmdLine)
+	if err != nil {
+		return false, "", nil
+	}
+	version := strings.TrimSpace(out)
+	return satisfiesVersion(version, pkg.constraints), version, nil
+}
+
+// verifyVersionsHelper makes sure the packages that were just installed satisfy their version constraints
+func (m *manager) verifyVersionsHelper(ctx context.Context, config RunConfig, vars envVariables, pkgs []*resolvedPkg) error {
+	if config.DryRun {
+		return nil
+	}
+	for _, pkg := range pkgs {
+		satisfied, version, err := m.versionSatisfiedHelper(ctx, config, vars, pkg)
+		if err != nil {
+			return err
+		}
+		if !satisfied {
+			return xerrors.Errorf("installed version `%v` of package `%v` does not satisfy `%v`", version, pkg.name, pkg.constraintString())
+		}
+	}
+	return nil
+}
+
+// checkPin makes sure the installer can install the pinned version of the package, or at least tell which version
+// it installed, since the pin would be ignored otherwise
+func (p resolvedPkg) checkPin() error {
+	version, pinned := pinnedVersion(p.constraints)
+	if !pinned || len(p.installer.VersionedPkg) > 0 || len(p.installer.Version) > 0 {
+		return nil
+	}
+	return xerrors.Errorf("package `%v` is pinned to version %v, but installer `%v` has no versioned_pkg or version command to honor it",
+		p.name, version, p.installer.Name)
+}
+
+func (p resolvedPkg) constraintString() string {
+	parts := make([]string, 0, len(p.constraints))
+	for _, c := range p.constraints {
+		parts = append(parts, c.op+c.version)
+	}
+	return strings.Join(parts, ",")
+}
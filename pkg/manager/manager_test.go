@@ -1 +1,213 @@
 package manager
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/morganhein/envy/pkg/io"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunTaskExpandsVars(t *testing.T) {
+	var ran []string
+	sh := &io.ShellMock{
+		RunFunc: func(ctx context.Context, printOnly bool, cmdLine string) (string, error) {
+			ran = append(ran, cmdLine)
+			return "", nil
+		},
+	}
+	m := New(io.NewFilesystem(), sh)
+	config := RunConfig{
+		Recipe: Recipe{
+			Vars: map[string]string{"prefix": "/opt/${os}"},
+			Tasks: map[string]Task{
+				"tools": {
+					Vars:     map[string]string{"bin": "${prefix}/bin"},
+					RunIf:    []string{"test -d ${prefix}"},
+					PreCmds:  []string{"mkdir -p ${bin}"},
+					PostCmds: []string{"echo ${CURRENT_TASK} $${bin}"},
+				},
+			},
+		},
+		facts: envVariables{FACT_OS: "linux"},
+	}
+	err := m.RunTask(context.Background(), config, "tools")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"test -d /opt/linux", "mkdir -p /opt/linux/bin", "echo tools ${bin}"}, ran)
+}
+
+func TestRunTaskUnresolvedVars(t *testing.T) {
+	var ran []string
+	sh := &io.ShellMock{
+		RunFunc: func(ctx context.Context, printOnly bool, cmdLine string) (string, error) {
+			ran = append(ran, cmdLine)
+			return "", nil
+		},
+	}
+	m := New(io.NewFilesystem(), sh)
+	config := RunConfig{
+		Recipe: Recipe{
+			Tasks: map[string]Task{
+				"broken": {PostCmds: []string{"rm -rf ${UNSET_ENVY_VAR}/"}},
+			},
+		},
+	}
+
+	t.Run("unresolved variables are left for the shell by default", func(t *testing.T) {
+		err := m.RunTask(context.Background(), config, "broken")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"rm -rf ${UNSET_ENVY_VAR}/"}, ran)
+	})
+
+	t.Run("strict mode refuses to run the command", func(t *testing.T) {
+		ran = nil
+		config.Strict = true
+		err := m.RunTask(context.Background(), config, "broken")
+		undefined := UndefinedVariableError{}
+		assert.ErrorAs(t, err, &undefined)
+		assert.Equal(t, UndefinedVariableError{Name: "UNSET_ENVY_VAR", Task: "broken", Step: "post_cmd"}, undefined)
+		assert.Empty(t, ran)
+	})
+}
+
+func TestInstallSkipsInstalledPackages(t *testing.T) {
+	var ran []string
+	sh := &io.ShellMock{
+		RunFunc: func(ctx context.Context, printOnly bool, cmdLine string) (string, error) {
+			ran = append(ran, cmdLine)
+			if cmdLine == "dpkg -s missing" {
+				return "", errors.New("package 'missing' is not installed")
+			}
+			return "", nil
+		},
+	}
+	m := New(io.NewFilesystem(), sh)
+	config := RunConfig{
+		Sudo: "false",
+		Recipe: Recipe{
+			InstallerDefs: map[string]Installer{
+				"apt": {
+					Cmd:    "${sudo} apt install -y ${pkg}",
+					Check:  "dpkg -s ${pkg}",
+					Update: "${sudo} apt update",
+				},
+			},
+		},
+	}
+	err := m.installPkgHelper(context.Background(), config, envVariables{}, "present")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"dpkg -s present"}, ran)
+
+	ran = nil
+	err = m.installPkgHelper(context.Background(), config, envVariables{}, "missing")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"dpkg -s missing", "apt update", "apt install -y missing"}, ran)
+}
+
+func TestInstallBatchesPackagesByInstaller(t *testing.T) {
+	var ran []string
+	failing := map[string]bool{}
+	sh := &io.ShellMock{
+		RunFunc: func(ctx context.Context, printOnly bool, cmdLine string) (string, error) {
+			ran = append(ran, cmdLine)
+			if strings.HasPrefix(cmdLine, "dpkg -s") && cmdLine != "dpkg -s git" {
+				return "", errors.New("not installed")
+			}
+			for pkg := range failing {
+				if strings.HasPrefix(cmdLine, "apt install") && strings.Contains(cmdLine, pkg) {
+					return "", errors.New("unable to locate package")
+				}
+			}
+			return "", nil
+		},
+	}
+	m := New(io.NewFilesystem(), sh)
+	config := RunConfig{
+		Sudo: "false",
+		Recipe: Recipe{
+			InstallerDefs: map[string]Installer{
+				"apt": {
+					Cmd:   "${sudo} apt install -y ${pkgs}",
+					Check: "dpkg -s ${pkg}",
+				},
+			},
+			Packages: map[string]Package{
+				"fd": {"apt": "fd-find"},
+			},
+		},
+	}
+
+	t.Run("packages are installed with a single invocation", func(t *testing.T) {
+		ran = nil
+		err := m.installPkgsHelper(context.Background(), config, envVariables{}, []string{"gcc", "git", "fd", "make"})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"dpkg -s gcc", "dpkg -s git", "dpkg -s fd-find", "dpkg -s make", "apt install -y gcc fd-find make"}, ran)
+	})
+
+	t.Run("a failing batch falls back to installing one at a time", func(t *testing.T) {
+		ran = nil
+		failing["nope"] = true
+		err := m.installPkgsHelper(context.Background(), config, envVariables{}, []string{"gcc", "nope", "make"})
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "`nope`")
+		assert.Equal(t, []string{"dpkg -s gcc", "dpkg -s nope", "dpkg -s make",
+			"apt install -y gcc nope make", "apt install -y gcc", "apt install -y nope"}, ran)
+	})
+}
+
+func TestInstallVersionedPackages(t *testing.T) {
+	var ran []string
+	installed := map[string]string{"golang": "1.19.4", "jq": "1.6"}
+	sh := &io.ShellMock{
+		RunFunc: func(ctx context.Context, printOnly bool, cmdLine string) (string, error) {
+			ran = append(ran, cmdLine)
+			switch {
+			case strings.HasPrefix(cmdLine, "version "):
+				return installed[strings.TrimPrefix(cmdLine, "version ")] + "\n", nil
+			case cmdLine == "apt install -y golang":
+				installed["golang"] = "1.21.1"
+			}
+			return "", nil
+		},
+	}
+	m := New(io.NewFilesystem(), sh)
+	config := RunConfig{
+		Sudo: "false",
+		Recipe: Recipe{
+			InstallerDefs: map[string]Installer{
+				"apt": {
+					Cmd:          "${sudo} apt install -y ${pkgs}",
+					Check:        "dpkg -s ${pkg}",
+					VersionedPkg: "${pkg}=${version}",
+					Version:      "version ${pkg}",
+				},
+			},
+			Packages: map[string]Package{
+				"golang":  {"version": ">=1.20"},
+				"jq":      {"version": ">=1.6"},
+				"ripgrep": {"version": "13.0.0"},
+			},
+		},
+	}
+	err := m.installPkgsHelper(context.Background(), config, envVariables{}, []string{"golang", "jq"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"dpkg -s golang", "version golang", "dpkg -s jq", "version jq",
+		"apt install -y golang", "version golang",
+	}, ran)
+
+	// the pinned version is passed to the installer, and is verified after installing
+	ran = nil
+	err = m.installPkgsHelper(context.Background(), config, envVariables{}, []string{"ripgrep"})
+	assert.Error(t, err)
+	assert.Contains(t, ran, "apt install -y ripgrep=13.0.0")
+
+	// an installer that can't honor the pin fails before installing anything
+	ran = nil
+	config.Recipe.InstallerDefs["apt"] = Installer{Cmd: "${sudo} apt install -y ${pkgs}", Check: "dpkg -s ${pkg}"}
+	err = m.installPkgsHelper(context.Background(), config, envVariables{}, []string{"ripgrep"})
+	assert.EqualError(t, err, "package `ripgrep` is pinned to version 13.0.0, but installer `apt` has no versioned_pkg or version command to honor it")
+	assert.Empty(t, ran)
+}
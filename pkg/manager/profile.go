@@ -0,0 +1,68 @@
+package manager
+
+import (
+	"path"
+	"sort"
+
+	"golang.org/x/xerrors"
+)
+
+// DEFAULT_PROFILE is applied when no other profile matches the machine
+const DEFAULT_PROFILE = "default"
+
+// selectProfile determines the profile to apply based on following precedence:
+// 1. Profile specified by command line
+// 2. The only profile whose hosts or facts match this machine
+// 3. The default profile
+func selectProfile(config RunConfig) (string, Profile, error) {
+	if config.Profile != "" {
+		p, ok := config.Recipe.Profiles[config.Profile]
+		if !ok {
+			return "", Profile{}, xerrors.Errorf("profile '%v' not defined in config", config.Profile)
+		}
+		return config.Profile, p, nil
+	}
+	var matched []string
+	for name, p := range config.Recipe.Profiles {
+		if profileMatches(p, config.facts) {
+			matched = append(matched, name)
+		}
+	}
+	sort.Strings(matched)
+	if len(matched) > 1 {
+		return "", Profile{}, xerrors.Errorf("more than one profile matches this machine (%v), choose one with --profile", matched)
+	}
+	if len(matched) == 1 {
+		return matched[0], config.Recipe.Profiles[matched[0]], nil
+	}
+	if p, ok := config.Recipe.Profiles[DEFAULT_PROFILE]; ok {
+		return DEFAULT_PROFILE, p, nil
+	}
+	return "", Profile{}, xerrors.New("no profile matches this machine, choose one with --profile")
+}
+
+// profileMatches is true if any of the hosts globs match the hostname, and all the facts globs match.
+// A profile without hosts or facts never matches, and can only be chosen explicitly.
+func profileMatches(p Profile, facts envVariables) bool {
+	if len(p.Hosts) == 0 && len(p.Facts) == 0 {
+		return false
+	}
+	if len(p.Hosts) > 0 {
+		hostMatched := false
+		for _, glob := range p.Hosts {
+			if ok, _ := path.Match(glob, facts[FACT_HOSTNAME]); ok {
+				hostMatched = true
+				break
+			}
+		}
+		if !hostMatched {
+			return false
+		}
+	}
+	for fact, glob := range p.Facts {
+		if ok, _ := path.Match(glob, facts[fact]); !ok {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,77 @@
+package manager
+
+import (
+	"context"
+	"testing"
+
+	"github.com/morganhein/envy/pkg/io"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSelectProfile(t *testing.T) {
+	profiles := map[string]Profile{
+		"laptop":  {Hosts: []string{"laptop-*", "thinkpad"}},
+		"mac":     {Facts: map[string]string{FACT_OS: "darwin"}},
+		"ci":      {Hosts: []string{"runner-*"}, Facts: map[string]string{FACT_DISTRO: "debian"}},
+		"server":  {Tasks: []string{"base"}},
+		"default": {Tasks: []string{"base"}},
+	}
+	tests := []struct {
+		name     string
+		forced   string
+		facts    envVariables
+		expected string
+		err      bool
+	}{
+		{name: "hostname glob", facts: envVariables{FACT_HOSTNAME: "laptop-work", FACT_OS: "linux"}, expected: "laptop"},
+		{name: "facts", facts: envVariables{FACT_HOSTNAME: "studio", FACT_OS: "darwin"}, expected: "mac"},
+		{name: "hosts and facts must both match", facts: envVariables{FACT_HOSTNAME: "runner-1", FACT_DISTRO: "alpine"}, expected: "default"},
+		{name: "hosts and facts", facts: envVariables{FACT_HOSTNAME: "runner-1", FACT_DISTRO: "debian"}, expected: "ci"},
+		{name: "forced", forced: "server", facts: envVariables{FACT_HOSTNAME: "thinkpad"}, expected: "server"},
+		{name: "forced but undefined", forced: "desktop", err: true},
+		{name: "ambiguous", facts: envVariables{FACT_HOSTNAME: "thinkpad", FACT_OS: "darwin"}, err: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := RunConfig{Recipe: Recipe{Profiles: profiles}, Profile: test.forced, facts: test.facts}
+			name, _, err := selectProfile(config)
+			if test.err {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, name)
+		})
+	}
+}
+
+func TestRunApplyOverridesVars(t *testing.T) {
+	var ran []string
+	sh := &io.ShellMock{
+		RunFunc: func(ctx context.Context, printOnly bool, cmdLine string) (string, error) {
+			ran = append(ran, cmdLine)
+			return "", nil
+		},
+	}
+	m := New(io.NewFilesystem(), sh)
+	config := RunConfig{
+		Recipe: Recipe{
+			Vars: map[string]string{"prefix": "/usr/local", "bin": "${prefix}/bin"},
+			Tasks: map[string]Task{
+				"base":  {PostCmds: []string{"echo ${ORIGINAL_TASK} ${bin}"}},
+				"extra": {PostCmds: []string{"echo ${ORIGINAL_TASK}"}},
+			},
+			Profiles: map[string]Profile{
+				"laptop": {
+					Tasks: []string{"base", "extra"},
+					Vars:  map[string]string{"prefix": "/home/me/.local"},
+					Hosts: []string{"laptop"},
+				},
+			},
+		},
+		facts: envVariables{FACT_HOSTNAME: "laptop"},
+	}
+	err := m.RunApply(context.Background(), config)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"echo base /home/me/.local/bin", "echo extra"}, ran)
+}
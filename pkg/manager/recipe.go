@@ -6,53 +6,123 @@ import (
 	"os"
 	"strings"
 
-	"github.com/BurntSushi/toml"
-
 	"github.com/morganhein/envy/pkg/io"
 )
 
 type Recipe struct {
-	General       General              `toml:"general"`
-	Packages      map[string]Package   `toml:"pkg"`
-	Shells        map[string]Shell     `toml:"shell"`
-	InstallerDefs map[string]Installer `toml:"installer"`
-	Tasks         map[string]Task      `toml:"task"`
+	General       General              `toml:"general" json:"general,omitempty" yaml:"general,omitempty"`
+	Packages      map[string]Package   `toml:"pkg" json:"pkg,omitempty" yaml:"pkg,omitempty"`
+	Shells        map[string]Shell     `toml:"shell" json:"shell,omitempty" yaml:"shell,omitempty"`
+	InstallerDefs map[string]Installer `toml:"installer" json:"installer,omitempty" yaml:"installer,omitempty"`
+	Tasks         map[string]Task      `toml:"task" json:"task,omitempty" yaml:"task,omitempty"`
+	Vars          map[string]string    `toml:"vars" json:"vars,omitempty" yaml:"vars,omitempty"`
+	Profiles      map[string]Profile   `toml:"profile" json:"profile,omitempty" yaml:"profile,omitempty"`
+	Releases      map[string]Release   `toml:"release" json:"release,omitempty" yaml:"release,omitempty"`
+	Sync          Sync                 `toml:"sync" json:"sync,omitempty" yaml:"sync,omitempty"`
 }
 
 // The General section of a TOML config
 type General struct {
-	InstallerPreferences []string `toml:"installer_preferences"`
-	ConfigDir            string   `toml:"config_dir"`
-	HomeDir              string   `toml:"home_dir"`
+	InstallerPreferences []string `toml:"installer_preferences,omitempty" json:"installer_preferences,omitempty" yaml:"installer_preferences,omitempty"`
+	ConfigDir            string   `toml:"config_dir,omitempty" json:"config_dir,omitempty" yaml:"config_dir,omitempty"`
+	HomeDir              string   `toml:"home_dir,omitempty" json:"home_dir,omitempty" yaml:"home_dir,omitempty"`
+}
+
+// The Sync section of a TOML config, which configures `envy sync`
+type Sync struct {
+	Source     string   `toml:"source,omitempty" json:"source,omitempty" yaml:"source,omitempty"` // defaults to the config_dir
+	Target     string   `toml:"target,omitempty" json:"target,omitempty" yaml:"target,omitempty"` // defaults to the home_dir, then $HOME
+	Dirs       []string `toml:"dirs,omitempty" json:"dirs,omitempty" yaml:"dirs,omitempty"`
+	Ignores    []string `toml:"ignores,omitempty" json:"ignores,omitempty" yaml:"ignores,omitempty"` // in the gitignore format
+	Mode       string   `toml:"mode,omitempty" json:"mode,omitempty" yaml:"mode,omitempty"`          // link or copy
+	OnConflict string   `toml:"on_conflict,omitempty" json:"on_conflict,omitempty" yaml:"on_conflict,omitempty"`
+	LinkDirs   *bool    `toml:"link_dirs,omitempty" json:"link_dirs,omitempty" yaml:"link_dirs,omitempty"` // link directories whole, when set
+	// DirLinks overrides LinkDirs for a directory
+	DirLinks map[string]bool `toml:"dir_links,omitempty" json:"dir_links,omitempty" yaml:"dir_links,omitempty"`
 }
 
 // A task as define in a TOML config
 type Task struct {
-	Installers []string
-	RunIf      []string
-	SkipIf     []string
-	Download   []Downloads
-	Deps       []string
-	PreCmds    []string `toml:"pre_cmd"`
-	Install    []string
-	PostCmds   []string `toml:"post_cmd"`
+	Installers []string          `toml:"installers,omitempty" json:"installers,omitempty" yaml:"installers,omitempty"`
+	RunIf      []string          `toml:"run_if,omitempty" json:"run_if,omitempty" yaml:"run_if,omitempty"`
+	SkipIf     []string          `toml:"skip_if,omitempty" json:"skip_if,omitempty" yaml:"skip_if,omitempty"`
+	Download   []Downloads       `toml:"download,omitempty" json:"download,omitempty" yaml:"download,omitempty"`
+	Link       []string          `toml:"link,omitempty" json:"link,omitempty" yaml:"link,omitempty"` // "from [to]", relative to the source and target
+	Deps       []string          `toml:"deps,omitempty" json:"deps,omitempty" yaml:"deps,omitempty"`
+	PreCmds    []string          `toml:"pre_cmd,omitempty" json:"pre_cmd,omitempty" yaml:"pre_cmd,omitempty"`
+	Install    []string          `toml:"install,omitempty" json:"install,omitempty" yaml:"install,omitempty"`
+	PostCmds   []string          `toml:"post_cmd,omitempty" json:"post_cmd,omitempty" yaml:"post_cmd,omitempty"`
+	Vars       map[string]string `toml:"vars,omitempty" json:"vars,omitempty" yaml:"vars,omitempty"`
+	Teardown   *Teardown         `toml:"teardown,omitempty" json:"teardown,omitempty" yaml:"teardown,omitempty"`
+}
+
+// Teardown reverses a task, when it is run with `envy task --undo`
+type Teardown struct {
+	PreCmds  []string `toml:"pre_cmd,omitempty" json:"pre_cmd,omitempty" yaml:"pre_cmd,omitempty"`
+	Remove   []string `toml:"remove,omitempty" json:"remove,omitempty" yaml:"remove,omitempty"`
+	PostCmds []string `toml:"post_cmd,omitempty" json:"post_cmd,omitempty" yaml:"post_cmd,omitempty"`
+}
+
+// A profile is the set of tasks to run on a kind of machine, as defined in a TOML config
+type Profile struct {
+	Tasks []string          `toml:"tasks,omitempty" json:"tasks,omitempty" yaml:"tasks,omitempty"`
+	Vars  map[string]string `toml:"vars,omitempty" json:"vars,omitempty" yaml:"vars,omitempty"`
+	Hosts []string          `toml:"hosts,omitempty" json:"hosts,omitempty" yaml:"hosts,omitempty"` // hostname globs
+	Facts map[string]string `toml:"facts,omitempty" json:"facts,omitempty" yaml:"facts,omitempty"` // fact name to glob
+}
+
+// A release is a binary published as a release asset, such as on GitHub or GitLab, and is installed by
+// installers of the release type
+type Release struct {
+	URL     string            `toml:"url" json:"url" yaml:"url"` // may use ${version}, ${os} and ${arch}
+	Version string            `toml:"version,omitempty" json:"version,omitempty" yaml:"version,omitempty"`
+	Binary  string            `toml:"binary,omitempty" json:"binary,omitempty" yaml:"binary,omitempty"` // defaults to the release name
+	OS      map[string]string `toml:"os,omitempty" json:"os,omitempty" yaml:"os,omitempty"`             // maps the os fact to the asset naming
+	Arch    map[string]string `toml:"arch,omitempty" json:"arch,omitempty" yaml:"arch,omitempty"`       // maps the arch fact to the asset naming
+	SHA256  map[string]string `toml:"sha256,omitempty" json:"sha256,omitempty" yaml:"sha256,omitempty"` // keyed by "os/arch"
+	BinDir  string            `toml:"bin_dir,omitempty" json:"bin_dir,omitempty" yaml:"bin_dir,omitempty"`
 }
 
 type Shell struct {
-	Download []Downloads `toml:"download"`
-	Cmds     []string    `toml:"cmds"`
+	Download []Downloads `toml:"download,omitempty" json:"download,omitempty" yaml:"download,omitempty"`
+	Cmds     []string    `toml:"cmds,omitempty" json:"cmds,omitempty" yaml:"cmds,omitempty"`
 }
 
-type Downloads []string
+// Downloads is a file to download, written either as a pair of the source url and the target location,
+// or as a table with options, such as extracting an archive into the target directory
+type Downloads struct {
+	From            string   `toml:"from" json:"from" yaml:"from"`
+	To              string   `toml:"to" json:"to" yaml:"to"`
+	Extract         bool     `toml:"extract,omitempty" json:"extract,omitempty" yaml:"extract,omitempty"`
+	StripComponents int      `toml:"strip_components,omitempty" json:"strip_components,omitempty" yaml:"strip_components,omitempty"`
+	Include         []string `toml:"include,omitempty" json:"include,omitempty" yaml:"include,omitempty"`
+	SHA256          string   `toml:"sha256,omitempty" json:"sha256,omitempty" yaml:"sha256,omitempty"`
+	SHA512          string   `toml:"sha512,omitempty" json:"sha512,omitempty" yaml:"sha512,omitempty"`
+	Checksums       string   `toml:"checksums,omitempty" json:"checksums,omitempty" yaml:"checksums,omitempty"` // url of a checksums file
+	Signature       string   `toml:"signature,omitempty" json:"signature,omitempty" yaml:"signature,omitempty"` // url of a minisign or signify signature
+	PublicKey       string   `toml:"public_key,omitempty" json:"public_key,omitempty" yaml:"public_key,omitempty"`
+	// OS and Arch map the os and arch of this machine to the names used in the urls, such as amd64 to x86_64
+	OS   map[string]string `toml:"os,omitempty" json:"os,omitempty" yaml:"os,omitempty"`
+	Arch map[string]string `toml:"arch,omitempty" json:"arch,omitempty" yaml:"arch,omitempty"`
+}
 
 // An installer definition from a TOML config
 type Installer struct {
-	Name    string
-	RunIf   []string `toml:"run_if"`
-	Sudo    bool
-	Cmd     string
-	Update  string
-	Updated bool
+	Name         string   `toml:"-" json:"-" yaml:"-"`
+	Type         string   `toml:"type,omitempty" json:"type,omitempty" yaml:"type,omitempty"`
+	RunIf        []string `toml:"run_if,omitempty" json:"run_if,omitempty" yaml:"run_if,omitempty"`
+	SkipIf       []string `toml:"skip_if,omitempty" json:"skip_if,omitempty" yaml:"skip_if,omitempty"`
+	Sudo         bool     `toml:"sudo" json:"sudo" yaml:"sudo"`
+	Explicit     bool     `toml:"explicit,omitempty" json:"explicit,omitempty" yaml:"explicit,omitempty"`
+	Cmd          string   `toml:"cmd,omitempty" json:"cmd,omitempty" yaml:"cmd,omitempty"`
+	Update       string   `toml:"update,omitempty" json:"update,omitempty" yaml:"update,omitempty"`
+	Check        string   `toml:"check,omitempty" json:"check,omitempty" yaml:"check,omitempty"`
+	Remove       string   `toml:"remove,omitempty" json:"remove,omitempty" yaml:"remove,omitempty"`
+	Upgrade      string   `toml:"upgrade,omitempty" json:"upgrade,omitempty" yaml:"upgrade,omitempty"`
+	UpgradePkg   string   `toml:"upgrade_pkg,omitempty" json:"upgrade_pkg,omitempty" yaml:"upgrade_pkg,omitempty"`
+	VersionedPkg string   `toml:"versioned_pkg,omitempty" json:"versioned_pkg,omitempty" yaml:"versioned_pkg,omitempty"`
+	Version      string   `toml:"version,omitempty" json:"version,omitempty" yaml:"version,omitempty"`
+	Updated      bool     `toml:"-" json:"-" yaml:"-"`
 }
 
 // A package alias as defined in a TOML config
@@ -60,6 +130,12 @@ type Installer struct {
 // package name for the specific installer.
 type Package map[string]string
 
+// Keys of a Package that are settings, rather than installer names
+const (
+	PKG_PREFER  = "prefer"
+	PKG_VERSION = "version"
+)
+
 func ResolveRecipe(fs io.Filesystem, configLocation string) (*Recipe, error) {
 	recipes, err := loadAllRecipes(fs, configLocation)
 	if err != nil {
@@ -80,19 +156,28 @@ func loadAllRecipes(fs io.Filesystem, configLocation string) ([]Recipe, error) {
 		return nil, err
 	}
 	locations := []string{
-		"/usr/share/envy/default.toml",
-		"$HOME/.envy/default.toml",
-		"$HOME/.config/envy/default.toml",
-		"$HOME/.envy/config.toml",
-		"$HOME/.config/envy/config.toml",
-		configLocation,
+		"/usr/share/envy/default",
+		"$HOME/.envy/default",
+		"$HOME/.config/envy/default",
+		"$HOME/.envy/config",
+		"$HOME/.config/envy/config",
 	}
 	for _, loc := range locations {
-		c, err := loadRecipeFromFS(fs, strings.Replace(loc, "$HOME", home, 1))
-		if err == nil {
-			recipes = append(recipes, *c)
+		for _, ext := range recipeExtensions {
+			c, err := loadRecipeFromFS(fs, strings.Replace(loc, "$HOME", home, 1)+ext)
+			if err == nil {
+				recipes = append(recipes, *c)
+			}
 		}
 	}
+	// the recipe given with --config has to load, while the default locations are optional
+	if len(configLocation) > 0 {
+		c, err := loadRecipeFromFS(fs, configLocation)
+		if err != nil {
+			return nil, err
+		}
+		recipes = append(recipes, *c)
+	}
 	if len(locations) == 0 {
 		return nil, xerrors.New("No configuration files found, none could be loaded.")
 	}
@@ -103,18 +188,23 @@ func loadRecipeFromFS(fs io.Filesystem, location string) (*Recipe, error) {
 	if location == "" {
 		return nil, errors.New("config location is empty")
 	}
+	format := FormatFromPath(location)
 	f, err := fs.ReadFile(location)
 	if err != nil {
 		return nil, err
 	}
-	k := &Recipe{}
-	_, err = toml.Decode(string(f), k)
+	k, err := DecodeRecipe(format, f)
 	if err != nil {
-		return nil, err
+		return nil, xerrors.Errorf("error decoding %v: %v", location, err)
 	}
 	return k, nil
 }
 
+// LoadRecipe loads a single recipe from location, without composing it with any of the default recipes
+func LoadRecipe(fs io.Filesystem, location string) (*Recipe, error) {
+	return loadRecipeFromFS(fs, location)
+}
+
 // Finds the package <name> in the config if found, otherwise returns package with default settings matching <name>
 // TODO: clean this up. Why is it always returning a package? how is this used? should we signal back when we don't have a package?
 func getPackage(config Recipe, name string) Package {
@@ -153,6 +243,26 @@ func overwriteRecipe(original Recipe, addition Recipe) Recipe {
 	for shellName, shell := range addition.Shells {
 		original.Shells[shellName] = shell
 	}
+	if original.Vars == nil {
+		original.Vars = map[string]string{}
+	}
+	for varName, v := range addition.Vars {
+		original.Vars[varName] = v
+	}
+	if original.Profiles == nil {
+		original.Profiles = map[string]Profile{}
+	}
+	for profileName, profile := range addition.Profiles {
+		original.Profiles[profileName] = profile
+	}
+	if original.Releases == nil {
+		original.Releases = map[string]Release{}
+	}
+	for releaseName, release := range addition.Releases {
+		original.Releases[releaseName] = release
+	}
+	original.General = overwriteGeneral(original.General, addition.General)
+	original.Sync = overwriteSync(original.Sync, addition.Sync)
 	return original
 }
 
@@ -182,5 +292,118 @@ func combineRecipe(original Recipe, addition Recipe) Recipe {
 			original.Tasks[taskName] = task
 		}
 	}
+	if original.Vars == nil {
+		original.Vars = map[string]string{}
+	}
+	for varName, v := range addition.Vars {
+		if _, alreadyExists := original.Vars[varName]; !alreadyExists {
+			original.Vars[varName] = v
+		}
+	}
+	if original.Profiles == nil {
+		original.Profiles = map[string]Profile{}
+	}
+	for profileName, profile := range addition.Profiles {
+		if _, alreadyExists := original.Profiles[profileName]; !alreadyExists {
+			original.Profiles[profileName] = profile
+		}
+	}
+	if original.Releases == nil {
+		original.Releases = map[string]Release{}
+	}
+	for releaseName, release := range addition.Releases {
+		if _, alreadyExists := original.Releases[releaseName]; !alreadyExists {
+			original.Releases[releaseName] = release
+		}
+	}
+	original.General = overwriteGeneral(addition.General, original.General)
+	original.Sync = overwriteSync(addition.Sync, original.Sync)
 	return original
 }
+
+// overwriteGeneral keeps the settings of the original that the addition doesn't set
+func overwriteGeneral(original General, addition General) General {
+	if len(addition.InstallerPreferences) > 0 {
+		original.InstallerPreferences = addition.InstallerPreferences
+	}
+	if len(addition.ConfigDir) > 0 {
+		original.ConfigDir = addition.ConfigDir
+	}
+	if len(addition.HomeDir) > 0 {
+		original.HomeDir = addition.HomeDir
+	}
+	return original
+}
+
+// overwriteSync keeps the settings of the original that the addition doesn't set. The ignores of both are kept,
+// and since the last ignore that matches wins, the addition's come last.
+func overwriteSync(original Sync, addition Sync) Sync {
+	if len(addition.Source) > 0 {
+		original.Source = addition.Source
+	}
+	if len(addition.Target) > 0 {
+		original.Target = addition.Target
+	}
+	if len(addition.Dirs) > 0 {
+		original.Dirs = addition.Dirs
+	}
+	original.Ignores = append(append([]string{}, original.Ignores...), addition.Ignores...)
+	if len(addition.Mode) > 0 {
+		original.Mode = addition.Mode
+	}
+	if len(addition.OnConflict) > 0 {
+		original.OnConflict = addition.OnConflict
+	}
+	// a pointer, so that a later recipe can turn it back off
+	if addition.LinkDirs != nil {
+		original.LinkDirs = addition.LinkDirs
+	}
+	if len(addition.DirLinks) > 0 {
+		dirLinks := map[string]bool{}
+		for dir, link := range original.DirLinks {
+			dirLinks[dir] = link
+		}
+		for dir, link := range addition.DirLinks {
+			dirLinks[dir] = link
+		}
+		original.DirLinks = dirLinks
+	}
+	return original
+}
+
+// SyncSettings returns the [sync] section of the recipe, with the source and target defaulting to the config_dir
+// and home_dir of the [general] section, and the target then to $HOME. Environment variables and a leading ~ are
+// expanded.
+func SyncSettings(r Recipe) (Sync, error) {
+	settings := r.Sync
+	if len(settings.Source) == 0 {
+		settings.Source = r.General.ConfigDir
+	}
+	if len(settings.Target) == 0 {
+		settings.Target = r.General.HomeDir
+	}
+	if len(settings.Target) == 0 {
+		settings.Target = "~"
+	}
+	var err error
+	if settings.Source, err = expandPath(settings.Source); err != nil {
+		return settings, err
+	}
+	if settings.Target, err = expandPath(settings.Target); err != nil {
+		return settings, err
+	}
+	return settings, nil
+}
+
+// expandPath expands the environment variables in a path, and a leading ~ to the home directory
+func expandPath(p string) (string, error) {
+	p = os.ExpandEnv(p)
+	if p != "~" && !strings.HasPrefix(p, "~/") {
+		return p, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", xerrors.Errorf("unable to expand %v: %v", p, err)
+	}
+	return home + strings.TrimPrefix(p, "~"), nil
+}
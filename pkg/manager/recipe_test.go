@@ -10,4 +10,197 @@ func TestResolveRecipe(t *testing.T) {
 	r, err := ResolveRecipe(io.NewFilesystem(), "../../configs/examples/package.toml")
 	assert.NoError(t, err)
 	assert.NotEmpty(t, r.Shells["asdf"])
+
+	// the recipe given with --config has to load
+	_, err = ResolveRecipe(io.NewFilesystem(), "../../configs/examples/missing.toml")
+	assert.Error(t, err)
+}
+
+func TestFormatFromPath(t *testing.T) {
+	assert.Equal(t, YAML, FormatFromPath("recipe.yml"))
+	assert.Equal(t, JSON, FormatFromPath("recipe.JSON"))
+	assert.Equal(t, TOML, FormatFromPath("recipe.toml"))
+	assert.Equal(t, TOML, FormatFromPath("recipe.conf"))
+	assert.Equal(t, TOML, FormatFromPath("config"))
+	assert.True(t, HasRecipeExtension("recipe.YAML"))
+	assert.False(t, HasRecipeExtension("recipe.txt"))
+}
+
+func TestDecodeRecipeFormats(t *testing.T) {
+	tomlRecipe := `
+[installer.apt]
+    run_if = ["which apt"]
+    sudo = true
+    cmd = "${sudo} apt install -y ${pkg}"
+
+[task.vim]
+    run_if = ["which bash"]
+    install = ["vim"]
+    post_cmd = ["echo done"]
+`
+	yamlRecipe := `
+installer:
+  apt:
+    run_if: ["which apt"]
+    sudo: true
+    cmd: "${sudo} apt install -y ${pkg}"
+task:
+  vim:
+    run_if: ["which bash"]
+    install: ["vim"]
+    post_cmd: ["echo done"]
+`
+	jsonRecipe := `{
+  "installer": {"apt": {"run_if": ["which apt"], "sudo": true, "cmd": "${sudo} apt install -y ${pkg}"}},
+  "task": {"vim": {"run_if": ["which bash"], "install": ["vim"], "post_cmd": ["echo done"]}}
+}`
+	expected, err := DecodeRecipe(TOML, []byte(tomlRecipe))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"which bash"}, expected.Tasks["vim"].RunIf)
+
+	fromYAML, err := DecodeRecipe(YAML, []byte(yamlRecipe))
+	assert.NoError(t, err)
+	assert.Equal(t, expected, fromYAML)
+
+	fromJSON, err := DecodeRecipe(JSON, []byte(jsonRecipe))
+	assert.NoError(t, err)
+	assert.Equal(t, expected, fromJSON)
+
+	// and the recipe should survive a round trip through every format
+	for _, format := range []RecipeFormat{TOML, YAML, JSON} {
+		out, err := EncodeRecipe(format, *expected)
+		assert.NoError(t, err)
+		decoded, err := DecodeRecipe(format, out)
+		assert.NoError(t, err)
+		assert.Equal(t, expected.Tasks, decoded.Tasks, format)
+		assert.Equal(t, expected.InstallerDefs, decoded.InstallerDefs, format)
+	}
+}
+
+func TestDecodeRecipeValidation(t *testing.T) {
+	_, err := DecodeRecipe(JSON, []byte(`{"task": {"vim": {"download": [["only-a-source"]]}}}`))
+	assert.Error(t, err)
+	_, err = DecodeRecipe(TOML, []byte(`[task.vim]
+    download = [["only-a-source"]]`))
+	assert.Error(t, err)
+}
+
+func TestDecodeDownloads(t *testing.T) {
+	expected := []Downloads{
+		{From: "https://example.com/dotfiles.tar.gz", To: "/tmp"},
+		{From: "https://example.com/nvim.tar.gz", To: "~/.local", Extract: true, StripComponents: 1, Include: []string{"bin/*", "share"}},
+	}
+	recipes := map[RecipeFormat]string{
+		// TOML arrays can't mix pairs and tables
+		TOML: `
+[task.nvim]
+    download = [
+        { from = "https://example.com/dotfiles.tar.gz", to = "/tmp" },
+        { from = "https://example.com/nvim.tar.gz", to = "~/.local", extract = true, strip_components = 1, include = ["bin/*", "share"] },
+    ]`,
+		YAML: `
+task:
+  nvim:
+    download:
+      - ["https://example.com/dotfiles.tar.gz", "/tmp"]
+      - from: https://example.com/nvim.tar.gz
+        to: ~/.local
+        extract: true
+        strip_components: 1
+        include: ["bin/*", "share"]
+`,
+		JSON: `{"task": {"nvim": {"download": [
+  ["https://example.com/dotfiles.tar.gz", "/tmp"],
+  {"from": "https://example.com/nvim.tar.gz", "to": "~/.local", "extract": true, "strip_components": 1, "include": ["bin/*", "share"]}
+]}}}`,
+	}
+	for format, recipe := range recipes {
+		r, err := DecodeRecipe(format, []byte(recipe))
+		if !assert.NoError(t, err, format) {
+			continue
+		}
+		assert.Equal(t, expected, r.Tasks["nvim"].Download, format)
+
+		out, err := EncodeRecipe(format, *r)
+		assert.NoError(t, err, format)
+		decoded, err := DecodeRecipe(format, out)
+		assert.NoError(t, err, format)
+		assert.Equal(t, expected, decoded.Tasks["nvim"].Download, format)
+	}
+}
+
+func TestDecodeDownloadPairsTOML(t *testing.T) {
+	r, err := DecodeRecipe(TOML, []byte(`[task.vim]
+    download = [["https://example.com/vimrc", "~/"]]`))
+	assert.NoError(t, err)
+	assert.Equal(t, []Downloads{{From: "https://example.com/vimrc", To: "~/"}}, r.Tasks["vim"].Download)
+
+	// unknown keys are rejected in every format
+	recipes := map[RecipeFormat]string{
+		TOML: `[task.vim]
+    download = [{ from = "https://example.com/vimrc", to = "~/", strip_component = 1 }]`,
+		YAML: `
+task:
+  vim:
+    download:
+      - {from: "https://example.com/vimrc", to: "~/", strip_component: 1}
+`,
+		JSON: `{"task": {"vim": {"download": [{"from": "https://example.com/vimrc", "to": "~/", "strip_component": 1}]}}}`,
+	}
+	for format, recipe := range recipes {
+		_, err = DecodeRecipe(format, []byte(recipe))
+		if assert.Error(t, err, format) {
+			assert.Contains(t, err.Error(), `invalid download: unknown field "strip_component"`, format)
+		}
+	}
+}
+
+func TestValidateDownloadSignatures(t *testing.T) {
+	for _, section := range []string{"task", "shell"} {
+		_, err := DecodeRecipe(TOML, []byte(`[`+section+`.vim]
+    download = [{ from = "https://example.com/vimrc", to = "~/", signature = "https://example.com/vimrc.sig" }]`))
+		assert.EqualError(t, err, section+" `vim`: verifying the signature of `https://example.com/vimrc` needs both the signature and the public_key")
+	}
+}
+
+func TestSyncSettings(t *testing.T) {
+	t.Setenv("HOME", "/home/tester")
+	linkDirs, noLinkDirs := true, false
+	base := Recipe{
+		General: General{ConfigDir: "~/.dotfiles", InstallerPreferences: []string{"apt"}},
+		Sync:    Sync{Ignores: []string{"*.swp"}, Mode: "copy", LinkDirs: &noLinkDirs, DirLinks: map[string]bool{".config": false, ".local": true}},
+	}
+	user := Recipe{
+		General: General{HomeDir: "$HOME/sandbox"},
+		Sync:    Sync{Ignores: []string{"!keep.swp"}, Dirs: []string{".config/nvim"}, LinkDirs: &linkDirs, DirLinks: map[string]bool{".local": false}},
+	}
+	r := overwriteRecipe(overwriteRecipe(Recipe{}, base), user)
+	assert.Equal(t, []string{"apt"}, r.General.InstallerPreferences)
+
+	settings, err := SyncSettings(r)
+	assert.NoError(t, err)
+	assert.Equal(t, Sync{
+		Source:   "/home/tester/.dotfiles",
+		Target:   "/home/tester/sandbox",
+		Dirs:     []string{".config/nvim"},
+		Ignores:  []string{"*.swp", "!keep.swp"},
+		Mode:     "copy",
+		LinkDirs: &linkDirs,
+		DirLinks: map[string]bool{".config": false, ".local": false},
+	}, settings)
+
+	// a later recipe can turn link_dirs back off, while one that doesn't set it keeps it
+	r = overwriteRecipe(r, Recipe{Sync: Sync{LinkDirs: &noLinkDirs}})
+	assert.False(t, *r.Sync.LinkDirs)
+	r = overwriteRecipe(r, Recipe{Sync: Sync{Mode: "link"}})
+	assert.False(t, *r.Sync.LinkDirs)
+	decoded, err := DecodeRecipe(TOML, []byte("[sync]\n    link_dirs = false"))
+	assert.NoError(t, err)
+	assert.Equal(t, &noLinkDirs, decoded.Sync.LinkDirs)
+
+	// the sync section takes precedence over the general section, and the target defaults to $HOME
+	settings, err = SyncSettings(Recipe{General: General{ConfigDir: "/unused"}, Sync: Sync{Source: "/srv/dotfiles"}})
+	assert.NoError(t, err)
+	assert.Equal(t, "/srv/dotfiles", settings.Source)
+	assert.Equal(t, "/home/tester", settings.Target)
 }
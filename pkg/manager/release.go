@@ -0,0 +1,233 @@
+package manager
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/morganhein/envy/pkg/io"
+	"golang.org/x/xerrors"
+)
+
+// this file installs binaries published as release assets, for installers of the release type
+
+// RELEASE_INSTALLER is the installer type that installs packages from [release.<name>] definitions
+const RELEASE_INSTALLER = "release"
+
+// releaseState is what envy remembers about the releases it installed, keyed by release name
+type releaseState map[string]installedRelease
+
+type installedRelease struct {
+	Version string `json:"version"`
+	Path    string `json:"path"`
+}
+
+// releaseStatePath is the file the installed releases are recorded in, under $XDG_STATE_HOME/envy
+func releaseStatePath() (string, error) {
+	dir, err := io.StateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "releases.json"), nil
+}
+
+func loadReleaseState() (releaseState, error) {
+	state := releaseState{}
+	location, err := releaseStatePath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(location)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, xerrors.Errorf("error reading the release state: %v", err)
+	}
+	if err = json.Unmarshal(data, &state); err != nil {
+		return nil, xerrors.Errorf("error reading the release state `%v`: %v", location, err)
+	}
+	return state, nil
+}
+
+func saveReleaseState(state releaseState) error {
+	location, err := releaseStatePath()
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(location), 0755); err != nil {
+		return xerrors.Errorf("error creating the state directory: %v", err)
+	}
+	return os.WriteFile(location, data, 0644)
+}
+
+// resolvedRelease is a release with its templates filled in for this machine
+type resolvedRelease struct {
+	name    string
+	url     string
+	version string
+	sha256  string
+	binary  string
+	path    string // where the binary is installed
+}
+
+// resolveReleaseHelper fills in the release definition of the package for the os and arch of this machine
+func resolveReleaseHelper(config RunConfig, vars envVariables, pkg *resolvedPkg) (*resolvedRelease, error) {
+	release, ok := config.Recipe.Releases[pkg.installName]
+	if !ok {
+		return nil, xerrors.Errorf("package `%v`: release `%v` is not defined", pkg.name, pkg.installName)
+	}
+	if len(release.URL) == 0 {
+		return nil, xerrors.Errorf("release `%v` does not define a url", pkg.installName)
+	}
+	goos, goarch := runtime.GOOS, runtime.GOARCH
+	if v, ok := vars[FACT_OS]; ok {
+		goos = v
+	}
+	if v, ok := vars[FACT_ARCH]; ok {
+		goarch = v
+	}
+	version := release.Version
+	if pinned, ok := pinnedVersion(pkg.constraints); ok {
+		version = pinned
+	}
+	assetOS, assetArch := goos, goarch
+	if v, ok := release.OS[goos]; ok {
+		assetOS = v
+	}
+	if v, ok := release.Arch[goarch]; ok {
+		assetArch = v
+	}
+	r := strings.NewReplacer("${version}", version, "${os}", assetOS, "${arch}", assetArch)
+	url, err := injectStepVars(config, vars, fmt.Sprintf("release `%v` url", pkg.installName), r.Replace(release.URL), pkg.sudo)
+	if err != nil {
+		return nil, err
+	}
+
+	binary := release.Binary
+	if len(binary) == 0 {
+		binary = pkg.installName
+	}
+	binDir := release.BinDir
+	if len(binDir) == 0 {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, xerrors.Errorf("unable to determine the home directory: %v", err)
+		}
+		binDir = filepath.Join(home, ".local", "bin")
+	}
+	binDir, err = injectStepVars(config, vars, fmt.Sprintf("release `%v` bin_dir", pkg.installName), binDir, pkg.sudo)
+	if err != nil {
+		return nil, err
+	}
+	return &resolvedRelease{
+		name:    pkg.installName,
+		url:     url,
+		version: version,
+		sha256:  release.SHA256[goos+"/"+goarch],
+		binary:  binary,
+		path:    filepath.Join(binDir, binary),
+	}, nil
+}
+
+// isReleaseInstalled is true if the release was installed by envy at the same version, and is still there
+func isReleaseInstalled(state releaseState, release *resolvedRelease) bool {
+	installed, ok := state[release.name]
+	if !ok || installed.Version != release.version || installed.Path != release.path {
+		return false
+	}
+	_, err := os.Stat(release.path)
+	return err == nil
+}
+
+// installReleaseHelper downloads the release asset, verifies it, and puts the binary in place.
+// Releases that are already installed at the requested version are skipped.
+func (m *manager) installReleaseHelper(ctx context.Context, config RunConfig, vars envVariables, pkg *resolvedPkg) error {
+	release, err := resolveReleaseHelper(config, vars, pkg)
+	if err != nil {
+		return err
+	}
+	state, err := loadReleaseState()
+	if err != nil {
+		return err
+	}
+	if isReleaseInstalled(state, release) {
+		fmt.Printf("%v: ok\n", pkg.name)
+		return nil
+	}
+	if config.DryRun {
+		fmt.Printf("installing release %v %v from %v to %v\n", release.name, release.version, release.url, release.path)
+		return nil
+	}
+
+	tmp, err := os.MkdirTemp("", "envy-release-")
+	if err != nil {
+		return xerrors.Errorf("error creating a download directory: %v", err)
+	}
+	defer func() {
+		_ = os.RemoveAll(tmp)
+	}()
+	io.PrintVerboseF(config.Verbose, "downloading release `%v` from `%v`", release.name, release.url)
+	var opts []io.DownloadOption
+	if len(release.sha256) > 0 {
+		opts = append(opts, io.WithChecksum(io.SHA256, release.sha256))
+	} else {
+		io.PrintWarningF("release `%v` has no sha256 for this platform, skipping verification", release.name)
+	}
+	asset, err := m.downloader().Download(ctx, release.url, tmp, opts...)
+	if err != nil {
+		return xerrors.Errorf("error downloading release `%v`: %w", release.name, err)
+	}
+	if err = io.ExtractFile(asset, release.binary, release.path); err != nil {
+		return xerrors.Errorf("error installing release `%v`: %w", release.name, err)
+	}
+	state[release.name] = installedRelease{Version: release.version, Path: release.path}
+	if err = saveReleaseState(state); err != nil {
+		return err
+	}
+	fmt.Printf("%v: installed %v\n", pkg.name, release.path)
+	return nil
+}
+
+// removeReleaseHelper removes a binary installed by installReleaseHelper
+func (m *manager) removeReleaseHelper(ctx context.Context, config RunConfig, vars envVariables, pkg *resolvedPkg) error {
+	state, err := loadReleaseState()
+	if err != nil {
+		return err
+	}
+	installed, ok := state[pkg.installName]
+	if !ok {
+		fmt.Printf("%v: not installed\n", pkg.name)
+		return nil
+	}
+	if config.DryRun {
+		fmt.Printf("removing release %v from %v\n", pkg.installName, installed.Path)
+		return nil
+	}
+	if err = os.Remove(installed.Path); err != nil && !os.IsNotExist(err) {
+		return xerrors.Errorf("error removing release `%v`: %v", pkg.installName, err)
+	}
+	delete(state, pkg.installName)
+	return saveReleaseState(state)
+}
+
+// upgradeReleaseHelper installs the version of the release in the recipe, if the release was installed before
+func (m *manager) upgradeReleaseHelper(ctx context.Context, config RunConfig, vars envVariables, pkg *resolvedPkg) error {
+	state, err := loadReleaseState()
+	if err != nil {
+		return err
+	}
+	if _, ok := state[pkg.installName]; !ok {
+		fmt.Printf("%v: not installed\n", pkg.name)
+		return nil
+	}
+	return m.installReleaseHelper(ctx, config, vars, pkg)
+}
@@ -0,0 +1,110 @@
+package manager
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/morganhein/envy/pkg/io"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func tarGz(t *testing.T, name string, contents []byte) []byte {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(contents)), Typeflag: tar.TypeReg}))
+	_, err := tw.Write(contents)
+	require.NoError(t, err)
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+	return buf.Bytes()
+}
+
+func TestInstallRelease(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	binDir := t.TempDir()
+	asset := tarGz(t, "ripgrep-13.0.0-x86_64-unknown-linux-musl/rg", []byte("#!/bin/sh\necho rg\n"))
+	sum := sha256.Sum256(asset)
+
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.Path)
+		_, _ = w.Write(asset)
+	}))
+	defer server.Close()
+
+	m := New(io.NewFilesystem(), &io.ShellMock{})
+	config := RunConfig{
+		Sudo: "false",
+		Recipe: Recipe{
+			InstallerDefs: map[string]Installer{
+				"release": {Type: RELEASE_INSTALLER, Explicit: true},
+			},
+			Packages: map[string]Package{
+				"ripgrep": {"release": "ripgrep"},
+			},
+			Releases: map[string]Release{
+				"ripgrep": {
+					URL:     server.URL + "/${version}/ripgrep-${version}-${arch}-${os}.tar.gz",
+					Version: "13.0.0",
+					Binary:  "rg",
+					OS:      map[string]string{"linux": "unknown-linux-musl"},
+					Arch:    map[string]string{"amd64": "x86_64"},
+					SHA256:  map[string]string{"linux/amd64": hex.EncodeToString(sum[:])},
+					BinDir:  binDir,
+				},
+			},
+		},
+	}
+	vars := envVariables{FACT_OS: "linux", FACT_ARCH: "amd64"}
+	target := filepath.Join(binDir, "rg")
+
+	err := m.installPkgsHelper(context.Background(), config, vars, []string{"ripgrep"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"/13.0.0/ripgrep-13.0.0-x86_64-unknown-linux-musl.tar.gz"}, requests)
+	info, err := os.Stat(target)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0755), info.Mode().Perm())
+	state, err := loadReleaseState()
+	assert.NoError(t, err)
+	assert.Equal(t, installedRelease{Version: "13.0.0", Path: target}, state["ripgrep"])
+
+	t.Run("the same version is not downloaded again", func(t *testing.T) {
+		requests = nil
+		err := m.installPkgsHelper(context.Background(), config, vars, []string{"ripgrep"})
+		assert.NoError(t, err)
+		assert.Empty(t, requests)
+	})
+
+	t.Run("a checksum mismatch installs nothing", func(t *testing.T) {
+		r := config.Recipe.Releases["ripgrep"]
+		r.Version = "14.0.0"
+		r.SHA256 = map[string]string{"linux/amd64": "0000"}
+		config.Recipe.Releases["ripgrep"] = r
+		err := m.installPkgsHelper(context.Background(), config, vars, []string{"ripgrep"})
+		assert.Error(t, err)
+		state, err := loadReleaseState()
+		assert.NoError(t, err)
+		assert.Equal(t, "13.0.0", state["ripgrep"].Version)
+	})
+
+	t.Run("remove", func(t *testing.T) {
+		err := m.removePkgsHelper(context.Background(), config, vars, []string{"ripgrep"})
+		assert.NoError(t, err)
+		_, err = os.Stat(target)
+		assert.True(t, os.IsNotExist(err))
+		state, err := loadReleaseState()
+		assert.NoError(t, err)
+		assert.Empty(t, state)
+	})
+}
@@ -0,0 +1,126 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/morganhein/envy/pkg/io"
+	"golang.org/x/xerrors"
+)
+
+func (m *manager) RunRemove(ctx context.Context, config RunConfig, pkg string) error {
+	//start tracking environment variables
+	vars := envVariables{}
+	if err := hydrateEnvironment(config, vars); err != nil {
+		return err
+	}
+	io.PrintVerbose(config.Verbose, fmt.Sprintf("original environment variables: %+v", vars), nil)
+	return m.removePkgsHelper(ctx, config, vars, []string{pkg})
+}
+
+// RunUndo reverses a task by running its teardown
+func (m *manager) RunUndo(ctx context.Context, config RunConfig, task string) error {
+	//start tracking environment variables
+	vars := envVariables{}
+	if err := hydrateEnvironment(config, vars); err != nil {
+		return err
+	}
+	io.PrintVerbose(config.Verbose, fmt.Sprintf("original environment variables: %+v", vars), nil)
+	return m.undoTaskHelper(ctx, config, vars, task)
+}
+
+/*
+undoTaskHelper runs, in order:
+* The teardown pre_cmd commands
+* Removes the teardown packages, or the task's installed packages if it has no teardown section
+* The teardown post_cmd commands
+The task's deps are not undone, since other tasks may still need them.
+*/
+func (m *manager) undoTaskHelper(ctx context.Context, config RunConfig, vars envVariables, task string) error {
+	io.PrintVerbose(config.Verbose, fmt.Sprintf("undoing task [%v]", task), nil)
+	t, ok := config.Recipe.Tasks[task]
+	if !ok {
+		return xerrors.Errorf("task '%v' not defined in config", task)
+	}
+
+	vars = vars.copy()
+	vars[CURRENT_TASK] = task
+	if err := resolveVars(t.Vars, vars); err != nil {
+		return xerrors.Errorf("task '%v': %w", task, err)
+	}
+	sudo := determineSudo(config, nil)
+
+	teardown := Teardown{}
+	if t.Teardown != nil {
+		teardown = *t.Teardown
+	} else {
+		//remove in the reverse order of installation
+		for i := len(t.Install) - 1; i >= 0; i-- {
+			teardown.Remove = append(teardown.Remove, t.Install[i])
+		}
+	}
+
+	for _, cmd := range teardown.PreCmds {
+		if err := m.runCmdHelper(ctx, config, vars, "teardown pre_cmd", cmd); err != nil {
+			return err
+		}
+	}
+
+	pkgs, err := injectAllStepVars(config, vars, "teardown remove", teardown.Remove, sudo)
+	if err != nil {
+		return err
+	}
+	if err := m.removePkgsHelper(ctx, config, vars, pkgs); err != nil {
+		return err
+	}
+
+	for _, cmd := range teardown.PostCmds {
+		if err := m.runCmdHelper(ctx, config, vars, "teardown post_cmd", cmd); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removePkgsHelper removes the packages, resolving them the same way they are installed.
+// Packages the installer reports as not installed are skipped.
+func (m *manager) removePkgsHelper(ctx context.Context, config RunConfig, vars envVariables, pkgNames []string) error {
+	for _, pkgName := range pkgNames {
+		pkg, err := m.resolvePkgHelper(ctx, config, pkgName)
+		if err != nil {
+			return err
+		}
+		if pkg.installer.Type == RELEASE_INSTALLER {
+			if err := m.removeReleaseHelper(ctx, config, vars, pkg); err != nil {
+				return err
+			}
+			continue
+		}
+		if len(pkg.installer.Remove) == 0 {
+			return xerrors.Errorf("installer `%v` does not define a remove command", pkg.installer.Name)
+		}
+		if len(pkg.installer.Check) > 0 {
+			installed, err := m.isInstalledHelper(ctx, config, vars, pkg.installer, pkg.installName, pkg.sudo)
+			if err != nil {
+				return err
+			}
+			if !installed {
+				fmt.Printf("%v: not installed\n", pkg.name)
+				continue
+			}
+		}
+		cmdLine, err := injectStepVars(config, vars, fmt.Sprintf("installer `%v` remove", pkg.installer.Name),
+			installCommandVariableSubstitution(pkg.installer.Remove, pkg.installName, pkg.sudo), pkg.sudo)
+		if err != nil {
+			return err
+		}
+		io.PrintVerboseF(config.Verbose, "running command `%v`", cmdLine)
+		out, err := m.r.Run(ctx, config.DryRun, cmdLine)
+		if err != nil {
+			io.PrintVerbose(config.Verbose, out, err)
+			return xerrors.Errorf("error removing package `%v`: %w", pkg.name, err)
+		}
+		io.PrintVerboseF(config.Verbose, "package removal successful")
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/morganhein/envy/pkg/io"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunUndo(t *testing.T) {
+	var ran []string
+	sh := &io.ShellMock{
+		RunFunc: func(ctx context.Context, printOnly bool, cmdLine string) (string, error) {
+			ran = append(ran, cmdLine)
+			if cmdLine == "dpkg -s curl" {
+				return "", errors.New("not installed")
+			}
+			return "", nil
+		},
+	}
+	m := New(io.NewFilesystem(), sh)
+	config := RunConfig{
+		Sudo: "false",
+		Recipe: Recipe{
+			InstallerDefs: map[string]Installer{
+				"apt": {
+					Cmd:    "${sudo} apt install -y ${pkgs}",
+					Check:  "dpkg -s ${pkg}",
+					Remove: "${sudo} apt remove -y ${pkgs}",
+				},
+			},
+			Packages: map[string]Package{
+				"vim": {"apt": "vim-nox"},
+			},
+			Tasks: map[string]Task{
+				"editor": {
+					Deps:    []string{"git"},
+					Install: []string{"vim", "curl"},
+				},
+				"dotfiles": {
+					Install: []string{"stow"},
+					Teardown: &Teardown{
+						PreCmds:  []string{"stow -D ${CURRENT_TASK}"},
+						PostCmds: []string{"echo removed"},
+					},
+				},
+			},
+		},
+	}
+
+	t.Run("without a teardown the installed packages are removed in reverse", func(t *testing.T) {
+		ran = nil
+		err := m.RunUndo(context.Background(), config, "editor")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"dpkg -s curl", "dpkg -s vim-nox", "apt remove -y vim-nox"}, ran)
+	})
+
+	t.Run("the teardown replaces the default", func(t *testing.T) {
+		ran = nil
+		err := m.RunUndo(context.Background(), config, "dotfiles")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"stow -D dotfiles", "echo removed"}, ran)
+	})
+}
@@ -0,0 +1,211 @@
+package manager
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+// this file generates a JSON Schema for recipes, so that editors and pre-commit hooks can validate them
+
+const schemaDraft = "http://json-schema.org/draft-07/schema#"
+
+// schemaDescriptions are the descriptions of each recipe type, and of each of their keys as "Type.key".
+// Keep these in line with USAGE.md.
+var schemaDescriptions = map[string]string{
+	"Recipe":                        "An envy recipe, which declares the installers, packages and tasks used to bootstrap an environment.",
+	"Recipe.general":                "General settings that apply to the whole recipe.",
+	"Recipe.pkg":                    "Package name overrides, so a single package name can resolve to platform/os specific package names.",
+	"Recipe.shell":                  "Shell installers, which install a package by downloading files and running commands.",
+	"Recipe.installer":              "Installer definitions. You can add your own installer just by adding a few lines.",
+	"Recipe.task":                   "Tasks that can be run with `envy task <taskName>`.",
+	"Recipe.vars":                   "Variables available to every task, as ${name}. Values can reference facts, such as ${os} and ${arch}, and other variables.",
+	"Recipe.profile":                "Profiles, which select the tasks to run with `envy apply` for a kind of machine.",
+	"Recipe.release":                "Binaries published as release assets, such as on GitHub or GitLab, which are installed by the `release` installer.",
+	"Release":                       "A binary published as a release asset. Packages install it by naming the release for the `release` installer.",
+	"Release.url":                   "The url of the release asset, which may use the `version`, `os` and `arch` variables.",
+	"Release.version":               "The version to install. A version pinned by the package takes precedence.",
+	"Release.binary":                "The name of the binary in the asset, which defaults to the release name.",
+	"Release.os":                    "Maps the os fact to the os in the asset name, such as `darwin = \"apple-darwin\"`.",
+	"Release.arch":                  "Maps the arch fact to the arch in the asset name, such as `amd64 = \"x86_64\"`.",
+	"Release.sha256":                "The sha256 of the asset for each platform, keyed by `os/arch`, such as `linux/amd64`.",
+	"Release.bin_dir":               "The directory the binary is installed in, which defaults to ~/.local/bin.",
+	"Profile":                       "A profile, which is the set of tasks to run on a kind of machine. It is chosen with --profile, or automatically by matching the hosts or facts.",
+	"Profile.tasks":                 "The tasks to run, in order.",
+	"Profile.vars":                  "Variables that override the recipe variables when this profile is applied.",
+	"Profile.hosts":                 "Select this profile automatically when the hostname matches any of these globs.",
+	"Profile.facts":                 "Select this profile automatically when every fact matches its glob, for example `os = \"darwin\"`.",
+	"General":                       "General settings that apply to the whole recipe.",
+	"General.installer_preferences": "Allowed installers in order of preference.",
+	"General.config_dir":            "The source directory of your dotfiles, used by `envy sync` unless the [sync] section sets a source.",
+	"General.home_dir":              "The target directory to symlink your dotfiles into, used by `envy sync` unless the [sync] section sets a target.",
+	"Sync":                          "Settings for `envy sync`, which links the files of your dotfiles into your home directory.",
+	"Sync.source":                   "The directory of your dotfiles, which defaults to the config_dir.",
+	"Sync.target":                   "The directory to sync into, which defaults to the home_dir, and then to your home directory.",
+	"Sync.dirs":                     "The directories to sync, relative to the source and target, such as `.config/nvim`. The files at the top of the source are always synced. Without any, they are picked when running in a terminal, and are otherwise the directories in the source.",
+	"Sync.ignores":                  "Paths to never sync, in the gitignore format. `.git` is always ignored.",
+	"Sync.mode":                     "Whether the target links to the files of the source, with `link`, or has copies of them, with `copy`. Defaults to `link`.",
+	"Sync.on_conflict":              "Resolve every conflict without prompting: `backup`, `adopt`, `ignore` or `skip`.",
+	"Sync.link_dirs":                "Link each directory of the source whole, as a single link, instead of linking its files one by one. A directory with ignored files in it is still linked file by file.",
+	"Sync.dir_links":                "Whether to link a directory whole, keyed by the directory relative to the source and target, such as `\".config/nvim\" = true`. This overrides link_dirs for that directory.",
+	"Task":                          "A task, which is run with `envy task <taskName>`. The options are executed in the order they are listed.",
+	"Task.installers":               "Define which installers this task can be run with. If none of the installers are available, the task cannot run.",
+	"Task.run_if":                   "Only run this task if the specified command returns true.",
+	"Task.skip_if":                  "Skip this task if the command returns true.",
+	"Task.download":                 "Download the specified file(s) from the internet to the target location(s).",
+	"Task.link":                     "Link files or directories from the source into the target, as `from to`, or just `from` to link it to the same path in the target. The source and target are those of the [sync] section, and conflicts are resolved the same way as `envy sync`.",
+	"Task.deps":                     "Install the required packages/tasks before running the install command. Refer to other tasks by prefixing the task name with a hash tag \"#\".",
+	"Task.pre_cmd":                  "Run the specified command before running the install command. If this command fails, execution is halted.",
+	"Task.install":                  "The package(s) to install.",
+	"Task.post_cmd":                 "Run the specified command after running the install command. If this fails, the installation is not rolled back.",
+	"Task.vars":                     "Variables available to this task, which override the recipe variables.",
+	"Task.teardown":                 "How to reverse this task with `envy task --undo`. Without it, the installed packages are removed.",
+	"Teardown":                      "Reverses a task, when it is run with `envy task --undo`. The options are executed in the order they are listed.",
+	"Teardown.pre_cmd":              "Run the specified command before removing the packages.",
+	"Teardown.remove":               "The package(s) to remove.",
+	"Teardown.post_cmd":             "Run the specified command after removing the packages.",
+	"Shell":                         "A shell installer, which installs a package by downloading files and running commands.",
+	"Shell.download":                "Download the specified file(s) from the internet to the target location(s).",
+	"Shell.cmds":                    "The commands to run to install the package.",
+	"Recipe.sync":                   "Settings for `envy sync`.",
+	"Downloads":                     "A download, as a pair of the source url and the target location, or as a table with options.",
+	"Downloads.from":                "The source, as an http(s) url, a file:// url, or a path relative to the recipe. ${os} and ${arch} are mapped by the os and arch tables.",
+	"Downloads.to":                  "The target file, or directory when it ends with a / or already is one. Archives are extracted into it.",
+	"Downloads.os":                  "Maps the os of this machine to the name the urls use, such as darwin to apple-darwin.",
+	"Downloads.arch":                "Maps the arch of this machine to the name the urls use, such as amd64 to x86_64.",
+	"Downloads.extract":             "Extract the downloaded tar.gz, tar.xz, tar.bz2, tar or zip archive into the target directory.",
+	"Downloads.strip_components":    "Remove this many leading path components from the extracted files.",
+	"Downloads.sha256":              "The expected sha256 checksum of the download. The file is only moved into place if it matches.",
+	"Downloads.sha512":              "The expected sha512 checksum of the download. The file is only moved into place if it matches.",
+	"Downloads.checksums":           "The url of a checksums file, in the sha256sum or sha512sum format, which lists the checksum of the download.",
+	"Downloads.signature":           "The url of a minisign or signify signature of the download, which is verified against the public_key.",
+	"Downloads.public_key":          "The minisign or signify public key the signature must be made with.",
+	"Downloads.include":             "Only extract the files matching these globs. A glob that matches a directory extracts everything in it.",
+	"Installer":                     "An installer, such as a system package manager.",
+	"Installer.type":                "The installer type. The `release` type installs [release] definitions, and ignores the commands.",
+	"Installer.run_if":              "Only use this installer if the detection condition is true.",
+	"Installer.skip_if":             "Don't use this installer if the detection condition is true.",
+	"Installer.explicit":            "Only use this installer for packages that define a package name for it, or prefer it.",
+	"Installer.sudo":                "When using this installer, by default, run with sudo.",
+	"Installer.cmd":                 "The command to run when installing packages using this installer. Requires the `sudo` and `pkg` variables, or `pkgs` to install several packages with one invocation.",
+	"Installer.update":              "The command used by the installer to update its repo/cache information. This is run before the installer is used the first time.",
+	"Installer.check":               "The command used to check if a package is already installed, in which case it is not installed again. Requires the `pkg` variable.",
+	"Installer.remove":              "The command to run when removing packages using this installer. Requires the `sudo` and `pkg` variables.",
+	"Installer.upgrade":             "The command to run to upgrade everything installed by this installer, with `envy upgrade`.",
+	"Installer.upgrade_pkg":         "The command to run to upgrade specific packages, with `envy upgrade <pkg|task>`. Requires the `sudo` and `pkg` variables.",
+	"Installer.versioned_pkg":       "How this installer names a specific version of a package, using the `pkg` and `version` variables, such as `${pkg}=${version}`.",
+	"Installer.version":             "The command that prints the installed version of a package. Requires the `pkg` variable.",
+	"Package":                       "Installer specific package names, keyed by the installer name. The `prefer` key sets the installer to use for this package, and the `version` key pins a version, or sets a constraint such as `>=1.20`.",
+}
+
+// schemaAlternatives are the other ways a type can be written in a recipe, such as the short form of a download
+var schemaAlternatives = map[string][]interface{}{
+	"Downloads": {map[string]interface{}{
+		"type":     "array",
+		"items":    map[string]interface{}{"type": "string"},
+		"minItems": 2,
+		"maxItems": 2,
+	}},
+}
+
+// GenerateSchema generates a JSON Schema describing a recipe
+func GenerateSchema() ([]byte, error) {
+	definitions := map[string]interface{}{}
+	schemaForType(reflect.TypeOf(Recipe{}), definitions)
+	// the root is added to the definitions like every other struct, so hoist it to the top level
+	schema := definitions["Recipe"].(map[string]interface{})
+	delete(definitions, "Recipe")
+	schema["$schema"] = schemaDraft
+	schema["title"] = "envy recipe"
+	schema["definitions"] = definitions
+	return json.MarshalIndent(schema, "", "  ")
+}
+
+// schemaForType returns the schema for t. Named structs, and named types that carry a description,
+// are added to definitions and referenced.
+func schemaForType(t reflect.Type, definitions map[string]interface{}) map[string]interface{} {
+	_, described := schemaDescriptions[t.Name()]
+	if t.Name() != "" && (t.Kind() == reflect.Struct || described) {
+		if _, ok := definitions[t.Name()]; !ok {
+			// reserve the name first, in case the type is recursive
+			definitions[t.Name()] = nil
+			s := schemaForKind(t, definitions)
+			if alternatives, ok := schemaAlternatives[t.Name()]; ok {
+				s = map[string]interface{}{"oneOf": append(append([]interface{}{}, alternatives...), s)}
+			}
+			if desc, ok := schemaDescriptions[t.Name()]; ok {
+				s["description"] = desc
+			}
+			definitions[t.Name()] = s
+		}
+		return map[string]interface{}{"$ref": "#/definitions/" + t.Name()}
+	}
+	return schemaForKind(t, definitions)
+}
+
+func schemaForKind(t reflect.Type, definitions map[string]interface{}) map[string]interface{} {
+	switch t.Kind() {
+	case reflect.Struct:
+		properties := map[string]interface{}{}
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			name := schemaFieldName(field)
+			if name == "" {
+				continue
+			}
+			s := schemaForType(field.Type, definitions)
+			if desc, ok := schemaDescriptions[t.Name()+"."+name]; ok {
+				// $ref siblings are ignored by draft-07, so wrap them
+				if _, isRef := s["$ref"]; isRef {
+					s = map[string]interface{}{"allOf": []interface{}{s}}
+				}
+				s["description"] = desc
+			}
+			properties[name] = s
+		}
+		return map[string]interface{}{
+			"type":                 "object",
+			"properties":           properties,
+			"additionalProperties": false,
+		}
+	case reflect.Map:
+		return map[string]interface{}{
+			"type":                 "object",
+			"additionalProperties": schemaForType(t.Elem(), definitions),
+		}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{
+			"type":  "array",
+			"items": schemaForType(t.Elem(), definitions),
+		}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Ptr:
+		return schemaForType(t.Elem(), definitions)
+	}
+	return map[string]interface{}{}
+}
+
+// schemaFieldName returns the recipe key of a struct field, or an empty string if the field is not part of a recipe
+func schemaFieldName(field reflect.StructField) string {
+	if field.PkgPath != "" {
+		// unexported
+		return ""
+	}
+	tag := field.Tag.Get("json")
+	name := strings.Split(tag, ",")[0]
+	if name == "-" {
+		return ""
+	}
+	if name == "" {
+		return field.Name
+	}
+	return name
+}
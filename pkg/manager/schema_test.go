@@ -0,0 +1,30 @@
+package manager
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerateSchema(t *testing.T) {
+	out, err := GenerateSchema()
+	assert.NoError(t, err)
+	schema := map[string]interface{}{}
+	assert.NoError(t, json.Unmarshal(out, &schema))
+	assert.Equal(t, schemaDraft, schema["$schema"])
+	assert.Contains(t, schema["properties"], "task")
+
+	definitions := schema["definitions"].(map[string]interface{})
+	task := definitions["Task"].(map[string]interface{})
+	// unknown keys such as `post_cmds` must be rejected
+	assert.Equal(t, false, task["additionalProperties"])
+	properties := task["properties"].(map[string]interface{})
+	assert.Contains(t, properties, "post_cmd")
+	assert.NotContains(t, properties, "post_cmds")
+	assert.Equal(t, schemaDescriptions["Task.run_if"], properties["run_if"].(map[string]interface{})["description"])
+
+	installer := definitions["Installer"].(map[string]interface{})
+	assert.NotContains(t, installer["properties"], "Name")
+	assert.Contains(t, definitions, "Package")
+}
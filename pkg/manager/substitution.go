@@ -1,19 +1,38 @@
 package manager
 
 import (
+	"errors"
 	"fmt"
-	"os"
 	"regexp"
 	"strings"
+
+	"github.com/morganhein/envy/pkg/io"
 )
 
 //this package (substitution) is meant to facilitate variable substitution in command lines
 
 func installCommandVariableSubstitution(cmdLine, pkg string, sudo bool) string {
-	cmdLine = strings.Replace(cmdLine, "${pkg}", pkg, -1)
+	return batchCommandVariableSubstitution(cmdLine, []string{pkg}, sudo)
+}
+
+// batchCommandVariableSubstitution replaces ${pkgs} with all the packages, separated by spaces
+func batchCommandVariableSubstitution(cmdLine string, pkgs []string, sudo bool) string {
+	cmdLine = strings.Replace(cmdLine, "${pkgs}", strings.Join(pkgs, " "), -1)
+	cmdLine = strings.Replace(cmdLine, "${pkg}", strings.Join(pkgs, " "), -1)
 	return replaceSudo(cmdLine, sudo)
 }
 
+// versionedPkgSubstitution renders the versioned_pkg template of an installer, such as "${pkg}=${version}"
+func versionedPkgSubstitution(template, pkg, version string) string {
+	template = strings.Replace(template, "${pkg}", pkg, -1)
+	return strings.Replace(template, "${version}", version, -1)
+}
+
+// isBatchInstaller is true if the installer can install several packages with one command
+func isBatchInstaller(installer *Installer) bool {
+	return strings.Contains(installer.Cmd, "${pkgs}")
+}
+
 func replaceSudo(cmdLine string, sudo bool) string {
 	if sudo {
 		cmdLine = strings.Replace(cmdLine, "${sudo}", "sudo", -1)
@@ -26,33 +45,149 @@ func replaceSudo(cmdLine string, sudo bool) string {
 }
 
 // injectVars first tries to replace all ${SH} style variables with the envy configuration values,
-// then with any environment variables.
-func injectVars(cmdLine string, vars envVariables, sudo bool) string {
+// then with any environment variables. A reference that can't be resolved is an error.
+func injectVars(cmdLine string, vars envVariables, sudo bool) (string, error) {
 	//need to do sudo expansion first, since it's a special case
 	cmdLine = replaceSudo(cmdLine, sudo)
+	return expandVars(cmdLine, vars.lookup)
+}
+
+// injectStepVars is injectVars for a line used by a task step. In strict mode a reference that can't be resolved
+// is an error naming the task and step, otherwise it is left for the shell and a warning is printed.
+func injectStepVars(config RunConfig, vars envVariables, step, line string, sudo bool) (string, error) {
+	line = replaceSudo(line, sudo)
+	out, unresolved, err := expand(line, vars.lookup, config.Strict)
+	var undefined UndefinedVariableError
+	if errors.As(err, &undefined) {
+		undefined.Task = vars[CURRENT_TASK]
+		undefined.Step = step
+		return "", undefined
+	}
+	if err != nil {
+		return "", err
+	}
+	for _, name := range unresolved {
+		undefined = UndefinedVariableError{Name: name, Task: vars[CURRENT_TASK], Step: step}
+		io.PrintWarningF("%v, leaving it for the shell", undefined)
+	}
+	return out, nil
+}
 
-	for k, v := range vars {
-		cmdLine = strings.Replace(cmdLine, fmt.Sprintf("${%v}", strings.ToUpper(k)), v, -1)
-		cmdLine = strings.Replace(cmdLine, fmt.Sprintf("${%v}", strings.ToLower(k)), v, -1)
+// injectAllStepVars runs injectStepVars over every line
+func injectAllStepVars(config RunConfig, vars envVariables, step string, lines []string, sudo bool) ([]string, error) {
+	injected := make([]string, 0, len(lines))
+	for _, line := range lines {
+		l, err := injectStepVars(config, vars, step, line, sudo)
+		if err != nil {
+			return nil, err
+		}
+		injected = append(injected, l)
 	}
+	return injected, nil
+}
+
+// UndefinedVariableError is returned when a ${VAR} reference has no value and no default
+type UndefinedVariableError struct {
+	Name string
+	Task string // Task is the task using the variable, if known
+	Step string // Step is the step of the task using the variable, if known
+}
 
-	//now search for any leftover requests intended to get environment variables
-	//regular expressions...ewwwww you say.... But I like them!
-	reg := regexp.MustCompile(`\${(\w+)}`)
-	matches := reg.FindAllStringSubmatch(cmdLine, -1)
-	if matches == nil {
-		return cmdLine
+func (e UndefinedVariableError) Error() string {
+	msg := fmt.Sprintf("variable `%v` is not defined", e.Name)
+	if e.Step != "" {
+		msg = fmt.Sprintf("%v: %v", e.Step, msg)
+	}
+	if e.Task != "" {
+		msg = fmt.Sprintf("task `%v` %v", e.Task, msg)
 	}
-	for _, match := range matches {
-		//try to get the environment variable defined here
-		v := os.Getenv(match[1])
-		if v == "" {
-			//TODO (@morgan): possibly warning here that a leftover variable expression did not get expanded
+	return msg
+}
+
+var varName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// expandVars expands every ${NAME} and ${NAME:-default} reference in input, using lookup to find the values.
+// The default is used when the variable is unset or empty, and may itself contain references.
+// $${NAME} escapes a reference, and becomes the literal ${NAME}. Anything else in braces that isn't a plain
+// variable name, such as ${#arr[@]}, is left alone for the shell.
+func expandVars(input string, lookup func(name string) (string, bool)) (string, error) {
+	out, _, err := expand(input, lookup, true)
+	return out, err
+}
+
+// expand implements expandVars. When strict is false an unresolved reference is left in place instead of
+// being an error, and its name is returned in unresolved.
+func expand(input string, lookup func(name string) (string, bool), strict bool) (string, []string, error) {
+	var unresolved []string
+	var out strings.Builder
+	for i := 0; i < len(input); i++ {
+		if input[i] != '$' {
+			out.WriteByte(input[i])
+			continue
+		}
+		// escaped reference
+		if strings.HasPrefix(input[i:], "$${") {
+			out.WriteString("${")
+			i += 2
+			continue
+		}
+		if !strings.HasPrefix(input[i:], "${") {
+			out.WriteByte(input[i])
 			continue
 		}
-		cmdLine = strings.Replace(cmdLine, match[0], v, -1)
+		end := matchingBrace(input, i+1)
+		if end < 0 {
+			// unterminated, leave it for the shell to complain about
+			out.WriteString(input[i:])
+			break
+		}
+		expr := input[i+2 : end]
+		name, def, hasDefault := strings.Cut(expr, ":-")
+		if !varName.MatchString(name) {
+			out.WriteString(input[i : end+1])
+			i = end
+			continue
+		}
+		v, ok := lookup(name)
+		if !ok || (hasDefault && v == "") {
+			if !hasDefault {
+				if strict {
+					return "", nil, UndefinedVariableError{Name: name}
+				}
+				unresolved = append(unresolved, name)
+				out.WriteString(input[i : end+1])
+				i = end
+				continue
+			}
+			var more []string
+			var err error
+			v, more, err = expand(def, lookup, strict)
+			if err != nil {
+				return "", nil, err
+			}
+			unresolved = append(unresolved, more...)
+		}
+		out.WriteString(v)
+		i = end
+	}
+	return out.String(), unresolved, nil
+}
+
+// matchingBrace returns the index of the brace closing the one at start, or -1
+func matchingBrace(input string, start int) int {
+	depth := 0
+	for i := start; i < len(input); i++ {
+		switch input[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
 	}
-	return cmdLine
+	return -1
 }
 
 func clean(input string) string {
@@ -2,6 +2,8 @@ package manager
 
 import (
 	"testing"
+
+	"github.com/stretchr/testify/assert"
 )
 
 func TestInstallCommandVariableSubstitution(t *testing.T) {
@@ -45,3 +47,39 @@ func TestInstallCommandVariableSubstitution(t *testing.T) {
 		})
 	}
 }
+
+func TestExpandVars(t *testing.T) {
+	vars := envVariables{
+		"os":     "linux",
+		"PREFIX": "/opt/envy",
+		"EMPTY":  "",
+	}
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+		err      bool
+	}{
+		{name: "plain", input: "ls ${PREFIX}/bin", expected: "ls /opt/envy/bin"},
+		{name: "case insensitive", input: "echo ${OS}", expected: "echo linux"},
+		{name: "default unused", input: "${PREFIX:-/usr}", expected: "/opt/envy"},
+		{name: "default used when unset", input: "${MISSING:-/usr}", expected: "/usr"},
+		{name: "default used when empty", input: "${EMPTY:-/usr}", expected: "/usr"},
+		{name: "nested default", input: "${MISSING:-${PREFIX}/lib}", expected: "/opt/envy/lib"},
+		{name: "escaped", input: "echo $${PREFIX}", expected: "echo ${PREFIX}"},
+		{name: "shell only syntax is untouched", input: "echo ${#arr[@]} $HOME $$", expected: "echo ${#arr[@]} $HOME $$"},
+		{name: "undefined", input: "rm -rf ${MISSING}/", err: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result, err := expandVars(test.input, vars.lookup)
+			if test.err {
+				assert.Error(t, err)
+				assert.ErrorAs(t, err, &UndefinedVariableError{})
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, result)
+		})
+	}
+}
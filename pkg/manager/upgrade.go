@@ -0,0 +1,184 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/morganhein/envy/pkg/io"
+	"golang.org/x/xerrors"
+)
+
+// RunUpgrade upgrades everything installed by every available installer when name is empty. Otherwise name is
+// a task, whose referenced packages are upgraded, or a single package.
+func (m *manager) RunUpgrade(ctx context.Context, config RunConfig, name string) error {
+	//start tracking environment variables
+	vars := envVariables{}
+	if err := hydrateEnvironment(config, vars); err != nil {
+		return err
+	}
+	io.PrintVerbose(config.Verbose, fmt.Sprintf("original environment variables: %+v", vars), nil)
+	if name == "" {
+		return m.upgradeAllHelper(ctx, config, vars)
+	}
+	task := name
+	if name[0] == '#' {
+		task = name[1:]
+	}
+	if _, ok := config.Recipe.Tasks[task]; ok {
+		pkgs, err := collectTaskPkgs(config, vars, task, map[string]bool{})
+		if err != nil {
+			return err
+		}
+		return m.upgradePkgsHelper(ctx, config, vars, pkgs)
+	}
+	if name[0] == '#' {
+		return xerrors.Errorf("task '%v' not defined in config", task)
+	}
+	return m.upgradePkgsHelper(ctx, config, vars, []string{name})
+}
+
+// upgradeAllHelper runs the upgrade command of every available installer
+func (m *manager) upgradeAllHelper(ctx context.Context, config RunConfig, vars envVariables) error {
+	installers := determineAvailableInstallers(ctx, config.Recipe.InstallerDefs, m.d)
+	for _, installer := range installers {
+		installer := installer
+		if len(installer.Upgrade) == 0 {
+			io.PrintVerboseF(config.Verbose, "installer `%v` does not define an upgrade command, skipping", installer.Name)
+			continue
+		}
+		sudo := determineSudo(config, &installer)
+		if err := m.updateInstallerHelper(ctx, config, &installer, sudo); err != nil {
+			return err
+		}
+		cmdLine, err := injectStepVars(config, vars, fmt.Sprintf("installer `%v` upgrade", installer.Name),
+			replaceSudo(installer.Upgrade, sudo), sudo)
+		if err != nil {
+			return err
+		}
+		io.PrintVerboseF(config.Verbose, "running command `%v`", cmdLine)
+		out, err := m.r.Run(ctx, config.DryRun, cmdLine)
+		if err != nil {
+			io.PrintVerbose(config.Verbose, out, err)
+			return xerrors.Errorf("error upgrading with `%v`: %w", installer.Name, err)
+		}
+	}
+	return nil
+}
+
+// collectTaskPkgs gathers the packages a task installs, including the packages its deps install
+func collectTaskPkgs(config RunConfig, vars envVariables, task string, visited map[string]bool) ([]string, error) {
+	if visited[task] {
+		return nil, nil
+	}
+	visited[task] = true
+	t, ok := config.Recipe.Tasks[task]
+	if !ok {
+		return nil, xerrors.Errorf("task '%v' not defined in config", task)
+	}
+	vars = vars.copy()
+	vars[CURRENT_TASK] = task
+	if err := resolveVars(t.Vars, vars); err != nil {
+		return nil, xerrors.Errorf("task '%v': %w", task, err)
+	}
+	sudo := determineSudo(config, nil)
+
+	var pkgs []string
+	deps, err := injectAllStepVars(config, vars, "deps", t.Deps, sudo)
+	if err != nil {
+		return nil, err
+	}
+	for _, dep := range deps {
+		if len(dep) > 0 && dep[0] == '#' {
+			depPkgs, err := collectTaskPkgs(config, vars, dep[1:], visited)
+			if err != nil {
+				return nil, err
+			}
+			pkgs = append(pkgs, depPkgs...)
+			continue
+		}
+		pkgs = append(pkgs, dep)
+	}
+	install, err := injectAllStepVars(config, vars, "install", t.Install, sudo)
+	if err != nil {
+		return nil, err
+	}
+	return append(pkgs, install...), nil
+}
+
+// upgradePkgsHelper upgrades the packages with the upgrade_pkg command of the installer that resolves them.
+// Packages that are not installed, or have a version constraint, are skipped, and installers with a ${pkgs} command
+// upgrade their packages together.
+func (m *manager) upgradePkgsHelper(ctx context.Context, config RunConfig, vars envVariables, pkgNames []string) error {
+	var installerOrder []string
+	groups := map[string][]*resolvedPkg{}
+	for _, pkgName := range pkgNames {
+		pkg, err := m.resolvePkgHelper(ctx, config, pkgName)
+		if err != nil {
+			return err
+		}
+		if pkg.installer.Type == RELEASE_INSTALLER {
+			if err := m.upgradeReleaseHelper(ctx, config, vars, pkg); err != nil {
+				return err
+			}
+			continue
+		}
+		if len(pkg.constraints) > 0 {
+			// upgrade_pkg installs the latest version, which may not satisfy the constraint
+			fmt.Printf("%v: skipped, its version is constrained to %v\n", pkg.name, pkg.constraintString())
+			continue
+		}
+		if len(pkg.installer.UpgradePkg) == 0 {
+			return xerrors.Errorf("installer `%v` does not define an upgrade_pkg command", pkg.installer.Name)
+		}
+		if len(pkg.installer.Check) > 0 {
+			installed, err := m.isInstalledHelper(ctx, config, vars, pkg.installer, pkg.installName, pkg.sudo)
+			if err != nil {
+				return err
+			}
+			if !installed {
+				fmt.Printf("%v: not installed\n", pkg.name)
+				continue
+			}
+		}
+		if _, ok := groups[pkg.installer.Name]; !ok {
+			installerOrder = append(installerOrder, pkg.installer.Name)
+		}
+		groups[pkg.installer.Name] = append(groups[pkg.installer.Name], pkg)
+	}
+
+	for _, installerName := range installerOrder {
+		group := groups[installerName]
+		installer := group[0].installer
+		sudo := group[0].sudo
+		if err := m.updateInstallerHelper(ctx, config, installer, sudo); err != nil {
+			return err
+		}
+		var batches [][]string
+		if strings.Contains(installer.UpgradePkg, "${pkgs}") {
+			batch := make([]string, 0, len(group))
+			for _, pkg := range group {
+				batch = append(batch, pkg.installName)
+			}
+			batches = append(batches, batch)
+		} else {
+			for _, pkg := range group {
+				batches = append(batches, []string{pkg.installName})
+			}
+		}
+		for _, batch := range batches {
+			cmdLine, err := injectStepVars(config, vars, fmt.Sprintf("installer `%v` upgrade_pkg", installer.Name),
+				batchCommandVariableSubstitution(installer.UpgradePkg, batch, sudo), sudo)
+			if err != nil {
+				return err
+			}
+			io.PrintVerboseF(config.Verbose, "running command `%v`", cmdLine)
+			out, err := m.r.Run(ctx, config.DryRun, cmdLine)
+			if err != nil {
+				io.PrintVerbose(config.Verbose, out, err)
+				return xerrors.Errorf("error upgrading %v with `%v`: %w", batch, installer.Name, err)
+			}
+		}
+	}
+	return nil
+}
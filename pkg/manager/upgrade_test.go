@@ -0,0 +1,73 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/morganhein/envy/pkg/io"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunUpgrade(t *testing.T) {
+	var ran []string
+	sh := &io.ShellMock{
+		RunFunc: func(ctx context.Context, printOnly bool, cmdLine string) (string, error) {
+			ran = append(ran, cmdLine)
+			switch cmdLine {
+			case "which brew", "dpkg -s nano":
+				return "", errors.New("not found")
+			}
+			return "", nil
+		},
+	}
+	m := New(io.NewFilesystem(), sh)
+	config := RunConfig{
+		Sudo: "false",
+		Recipe: Recipe{
+			InstallerDefs: map[string]Installer{
+				"apt": {
+					RunIf:      []string{"which apt"},
+					Check:      "dpkg -s ${pkg}",
+					Update:     "apt update",
+					Upgrade:    "apt upgrade -y",
+					UpgradePkg: "apt install --only-upgrade -y ${pkgs}",
+				},
+				"brew": {
+					RunIf:   []string{"which brew"},
+					Upgrade: "brew upgrade",
+				},
+				"npm": {
+					RunIf: []string{"which npm"},
+				},
+			},
+			Packages: map[string]Package{
+				"vim":  {"prefer": "apt", "apt": "vim-nox"},
+				"curl": {"version": "=7.81.0"},
+			},
+			Tasks: map[string]Task{
+				"editor": {Deps: []string{"git", "#tools"}, Install: []string{"vim"}},
+				"tools":  {Install: []string{"curl", "nano"}, Deps: []string{"#editor"}},
+			},
+		},
+	}
+
+	t.Run("everything", func(t *testing.T) {
+		ran = nil
+		m.updatedInstallers = map[string]interface{}{}
+		err := m.RunUpgrade(context.Background(), config, "")
+		assert.NoError(t, err)
+		assert.Contains(t, ran, "apt update")
+		assert.Contains(t, ran, "apt upgrade -y")
+		assert.NotContains(t, ran, "brew upgrade")
+	})
+
+	t.Run("only the packages a task references", func(t *testing.T) {
+		ran = nil
+		config.ForceInstaller = "apt"
+		err := m.RunUpgrade(context.Background(), config, "editor")
+		assert.NoError(t, err)
+		// curl is pinned, so it isn't upgraded
+		assert.Equal(t, "apt install --only-upgrade -y git vim-nox", ran[len(ran)-1])
+	})
+}
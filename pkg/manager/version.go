@@ -0,0 +1,167 @@
+package manager
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+
+	"golang.org/x/xerrors"
+)
+
+// this file compares package versions against the constraints set by the `version` of a package
+
+// versionConstraint is a single comparison, such as ">=1.20"
+type versionConstraint struct {
+	op      string
+	version string
+}
+
+// parseVersionConstraints parses a comma separated list of constraints, which must all be met.
+// A version without an operator, like "1.17.2", is an exact pin.
+func parseVersionConstraints(input string) ([]versionConstraint, error) {
+	var constraints []versionConstraint
+	for _, part := range strings.Split(input, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		c := versionConstraint{op: "="}
+		for _, op := range []string{">=", "<=", "!=", "==", ">", "<", "="} {
+			if strings.HasPrefix(part, op) {
+				c.op = op
+				part = strings.TrimSpace(strings.TrimPrefix(part, op))
+				break
+			}
+		}
+		if c.op == "==" {
+			c.op = "="
+		}
+		if part == "" {
+			return nil, xerrors.Errorf("version constraint `%v` is missing a version", input)
+		}
+		c.version = part
+		constraints = append(constraints, c)
+	}
+	if len(constraints) == 0 {
+		return nil, xerrors.Errorf("version constraint `%v` is empty", input)
+	}
+	return constraints, nil
+}
+
+// pinnedVersion returns the version if the constraints are a single exact version, which can be passed to the installer
+func pinnedVersion(constraints []versionConstraint) (string, bool) {
+	if len(constraints) == 1 && constraints[0].op == "=" {
+		return constraints[0].version, true
+	}
+	return "", false
+}
+
+// satisfiesVersion is true if the version meets every constraint. An unknown version meets none.
+func satisfiesVersion(version string, constraints []versionConstraint) bool {
+	if len(versionSegments(version)) == 0 {
+		return len(constraints) == 0
+	}
+	for _, c := range constraints {
+		cmp := compareVersions(version, c.version)
+		ok := false
+		switch c.op {
+		case "=":
+			ok = matchesVersion(version, c.version)
+		case "!=":
+			ok = cmp != 0
+		case ">":
+			ok = cmp > 0
+		case ">=":
+			ok = cmp >= 0
+		case "<":
+			ok = cmp < 0
+		case "<=":
+			ok = cmp <= 0
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// compareVersions compares two versions segment by segment, numerically where the segments start with numbers.
+// A leading "v" and an epoch such as "1:" are ignored, and the segments missing from the shorter version count
+// as 0, so "1.20" is less than "1.20.3".
+func compareVersions(a, b string) int {
+	as, bs := versionSegments(a), versionSegments(b)
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		if cmp := compareSegments(segmentAt(as, i), segmentAt(bs, i)); cmp != 0 {
+			return cmp
+		}
+	}
+	return 0
+}
+
+// matchesVersion checks if the version starts with the segments of prefix, so "1.20" matches "1.20.3", which is
+// what people mean when they write `=1.20`.
+func matchesVersion(version, prefix string) bool {
+	vs, ps := versionSegments(version), versionSegments(prefix)
+	for i := range ps {
+		if compareSegments(segmentAt(vs, i), ps[i]) != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func segmentAt(segments []string, i int) string {
+	if i < len(segments) {
+		return segments[i]
+	}
+	return "0"
+}
+
+// compareSegments compares the numbers that segments start with first, so "10" is more than "9a", and then
+// the rest of them as text. Segments that don't start with a number mark a pre-release, such as the "rc1" of
+// "1.2.0-rc1", and come before any number, so the pre-release comes before the release.
+func compareSegments(a, b string) int {
+	an, aRest := splitSegment(a)
+	bn, bRest := splitSegment(b)
+	switch {
+	case an >= 0 && bn >= 0:
+		if an != bn {
+			if an < bn {
+				return -1
+			}
+			return 1
+		}
+		return strings.Compare(aRest, bRest)
+	case an >= 0:
+		return 1
+	case bn >= 0:
+		return -1
+	}
+	return strings.Compare(a, b)
+}
+
+// splitSegment splits a segment into the number it starts with, or -1 if it doesn't, and the rest
+func splitSegment(segment string) (int, string) {
+	i := strings.IndexFunc(segment, func(r rune) bool {
+		return !unicode.IsDigit(r)
+	})
+	if i < 0 {
+		i = len(segment)
+	}
+	n, err := strconv.Atoi(segment[:i])
+	if err != nil {
+		return -1, segment
+	}
+	return n, segment[i:]
+}
+
+func versionSegments(version string) []string {
+	version = strings.TrimSpace(version)
+	if i := strings.Index(version, ":"); i >= 0 {
+		version = version[i+1:]
+	}
+	version = strings.TrimPrefix(version, "v")
+	return strings.FieldsFunc(version, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
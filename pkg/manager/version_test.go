@@ -0,0 +1,79 @@
+package manager
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"1.20", "1.20", 0},
+		{"1.9", "1.20", -1},
+		{"1.20.3", "1.20", 1},
+		{"1.20.0", "1.20", 0},
+		{"v2.0.1", "2.0.0", 1},
+		{"1:8.2.3995-1ubuntu2", "8.2.3995", 1},
+		{"1.2.0-rc1", "1.2.0-rc2", -1},
+		{"1.2.0-rc1", "1.2.0", -1},
+		{"1.2.0", "1.2.0-beta", 1},
+		{"1.2.0-rc1", "1.2.0.1", -1},
+		{"10", "9a", 1},
+		{"1.9a", "1.10", -1},
+		{"1.2a", "1.2b", -1},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, compareVersions(tt.a, tt.b), "%v vs %v", tt.a, tt.b)
+	}
+}
+
+func TestSatisfiesVersion(t *testing.T) {
+	tests := []struct {
+		version    string
+		constraint string
+		want       bool
+	}{
+		{"1.20.3", ">=1.20", true},
+		{"1.19.9", ">=1.20", false},
+		{"1.21.0", ">=1.20, <2", true},
+		{"2.0.0", ">=1.20,<2", false},
+		{"1.17.2", "1.17.2", true},
+		{"1.17.3", "==1.17.2", false},
+		{"1.17.3", "!=1.17.2", true},
+		{"1.20.3", "=1.20", true},
+		{"1:8.2.3995-1ubuntu2", "=8.2", true},
+		{"1.2", "=1.2.0", true},
+		{"1.20.3", ">1.20", true},
+		{"1.20.3", "<=1.20", false},
+		{"1.20.3", "!=1.20", true},
+		{"1.20.0", "!=1.20", false},
+		{"1.2.0-rc1", ">=1.2.0", false},
+		{"", ">=1.0", false},
+	}
+	for _, tt := range tests {
+		constraints, err := parseVersionConstraints(tt.constraint)
+		assert.NoError(t, err)
+		assert.Equal(t, tt.want, satisfiesVersion(tt.version, constraints), "%v %v", tt.version, tt.constraint)
+	}
+}
+
+func TestParseVersionConstraints(t *testing.T) {
+	constraints, err := parseVersionConstraints("1.17.2")
+	assert.NoError(t, err)
+	version, pinned := pinnedVersion(constraints)
+	assert.True(t, pinned)
+	assert.Equal(t, "1.17.2", version)
+
+	constraints, err = parseVersionConstraints(">=1.20")
+	assert.NoError(t, err)
+	_, pinned = pinnedVersion(constraints)
+	assert.False(t, pinned)
+
+	_, err = parseVersionConstraints(">=")
+	assert.Error(t, err)
+	_, err = parseVersionConstraints(" , ")
+	assert.Error(t, err)
+}
@@ -0,0 +1,235 @@
+package sync
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/morganhein/envy/pkg/io"
+	"golang.org/x/xerrors"
+)
+
+// Files that sync replaces are moved into a backup directory for the run, $XDG_STATE_HOME/envy/backups/<timestamp>,
+// at the same path relative to the target. The manifest records where each file came from, so they can be restored.
+
+// manifestFile is kept in the backup directory, next to the files
+const manifestFile = ".envy-backup.json"
+
+// timestampFormat names the backup directories, so they sort by time
+const timestampFormat = "20060102-150405"
+
+// BackupEntry is a file that was backed up
+type BackupEntry struct {
+	Original string   `json:"original"`         // where the file was
+	Backup   string   `json:"backup"`           // where it is, relative to the backup directory
+	Source   string   `json:"source,omitempty"` // the file of the source that replaced it, unless it was adopted over
+	Mode     SyncMode `json:"mode,omitempty"`   // whether the source was linked or copied in its place
+}
+
+// BackupManifest lists the files backed up by one sync
+type BackupManifest struct {
+	Timestamp string        `json:"timestamp"`
+	Target    string        `json:"target"`
+	Entries   []BackupEntry `json:"entries"`
+}
+
+// DefaultBackupDir is $XDG_STATE_HOME/envy/backups
+func DefaultBackupDir() (string, error) {
+	dir, err := io.StateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "backups"), nil
+}
+
+type backupStore struct {
+	root     string // the directory of all the backups
+	dir      string // the directory of this run's backups
+	ops      *fileOps
+	manifest BackupManifest
+}
+
+func newBackupStore(root, target string, ops *fileOps, now time.Time) *backupStore {
+	timestamp := now.UTC().Format(timestampFormat)
+	// two runs in the same second get their own directories
+	for i := 1; ; i++ {
+		if _, err := os.Stat(filepath.Join(root, timestamp)); os.IsNotExist(err) {
+			break
+		}
+		timestamp = fmt.Sprintf("%v-%d", now.UTC().Format(timestampFormat), i)
+	}
+	return &backupStore{
+		root:     root,
+		dir:      filepath.Join(root, timestamp),
+		ops:      ops,
+		manifest: BackupManifest{Timestamp: timestamp, Target: target},
+	}
+}
+
+// Backup moves the file into the backup directory, and returns where it went. The file is about to be replaced by
+// the source, linked or copied by mode.
+func (b *backupStore) Backup(path, source string, mode SyncMode) (string, error) {
+	relative, err := filepath.Rel(b.manifest.Target, path)
+	if err != nil || strings.HasPrefix(relative, "..") {
+		// outside of the target, so keep the whole path
+		relative = strings.TrimPrefix(filepath.Clean(path), string(filepath.Separator))
+	}
+	backup := filepath.Join(b.dir, relative)
+	if err = b.ops.Rename(path, backup); err != nil {
+		return "", xerrors.Errorf("error backing up %v: %w", path, err)
+	}
+	b.manifest.Entries = append(b.manifest.Entries, BackupEntry{Original: path, Backup: relative, Source: source, Mode: mode})
+	return backup, b.save()
+}
+
+// save writes the manifest after every backup, so it's complete even if the sync fails part way
+func (b *backupStore) save() error {
+	if b.ops.dryRun {
+		return nil
+	}
+	data, err := json.MarshalIndent(b.manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = os.WriteFile(filepath.Join(b.dir, manifestFile), data, 0644); err != nil {
+		return xerrors.Errorf("error writing the backup manifest: %v", err)
+	}
+	return nil
+}
+
+// ListBackups returns the manifests of the backups, oldest first
+func ListBackups(root string) ([]BackupManifest, error) {
+	dirs, err := os.ReadDir(root)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, xerrors.Errorf("error reading the backups: %v", err)
+	}
+	var manifests []BackupManifest
+	for _, dir := range dirs {
+		if !dir.IsDir() {
+			continue
+		}
+		manifest, err := loadManifest(filepath.Join(root, dir.Name()))
+		if err != nil {
+			continue
+		}
+		manifests = append(manifests, *manifest)
+	}
+	sort.Slice(manifests, func(i, j int) bool {
+		return manifests[i].Timestamp < manifests[j].Timestamp
+	})
+	return manifests, nil
+}
+
+func loadManifest(dir string) (*BackupManifest, error) {
+	data, err := os.ReadFile(filepath.Join(dir, manifestFile))
+	if err != nil {
+		return nil, err
+	}
+	manifest := &BackupManifest{}
+	if err = json.Unmarshal(data, manifest); err != nil {
+		return nil, xerrors.Errorf("invalid backup manifest in %v: %v", dir, err)
+	}
+	return manifest, nil
+}
+
+// Restore puts the files of a backup back where they were, replacing the links sync made. Without a timestamp,
+// the latest backup is restored. Files that were changed since, including links that no longer point to the
+// source and copies that no longer match it, are left alone, and stay in the backup.
+func Restore(timestamp string, dryRun bool) error {
+	root, err := DefaultBackupDir()
+	if err != nil {
+		return err
+	}
+	ops := newFileOps(dryRun, os.Stdout)
+	if err = restoreHelper(root, timestamp, ops, io.NewLogger()); err != nil {
+		return err
+	}
+	if dryRun {
+		fmt.Println("dry run, nothing was changed. The changes would be:")
+		for _, op := range ops.ops {
+			fmt.Printf("  %v\n", op)
+		}
+	}
+	return nil
+}
+
+func restoreHelper(root, timestamp string, ops *fileOps, log io.Logger) error {
+	manifests, err := ListBackups(root)
+	if err != nil {
+		return err
+	}
+	if len(manifests) == 0 {
+		return xerrors.New("there are no backups to restore")
+	}
+	manifest := manifests[len(manifests)-1]
+	if len(timestamp) > 0 {
+		found := false
+		available := make([]string, 0, len(manifests))
+		for _, m := range manifests {
+			available = append(available, m.Timestamp)
+			if m.Timestamp == timestamp {
+				manifest, found = m, true
+			}
+		}
+		if !found {
+			return xerrors.Errorf("there is no backup `%v`, the backups are %v", timestamp, strings.Join(available, ", "))
+		}
+	}
+	dir := filepath.Join(root, manifest.Timestamp)
+	var remaining []BackupEntry
+	for _, entry := range manifest.Entries {
+		info, err := os.Lstat(entry.Original)
+		switch {
+		case err == nil && replacedBySync(entry, info):
+			// what sync put in its place
+			if err = ops.Remove(entry.Original); err != nil {
+				return err
+			}
+		case err == nil && len(entry.Source) == 0:
+			log.Warningf("not restoring %v, a file was adopted in its place. The backup stays at %v", entry.Original,
+				filepath.Join(dir, entry.Backup))
+			remaining = append(remaining, entry)
+			continue
+		case err == nil:
+			log.Warningf("not restoring %v, it has changed since it was backed up", entry.Original)
+			remaining = append(remaining, entry)
+			continue
+		case !os.IsNotExist(err):
+			return xerrors.Errorf("error reading %v: %v", entry.Original, err)
+		}
+		if err = ops.Rename(filepath.Join(dir, entry.Backup), entry.Original); err != nil {
+			return err
+		}
+	}
+	if len(remaining) == 0 {
+		return ops.Remove(dir)
+	}
+	// keep what wasn't restored, so it can be restored later
+	b := &backupStore{root: root, dir: dir, ops: ops, manifest: manifest}
+	b.manifest.Entries = remaining
+	return b.save()
+}
+
+// replacedBySync checks if the file at the original path of the entry is still what sync put there, a link to the
+// source, or in copy mode a copy of it
+func replacedBySync(entry BackupEntry, info os.FileInfo) bool {
+	if len(entry.Source) == 0 {
+		return false
+	}
+	if entry.Mode == CopyMode {
+		same, err := sameContents(entry.Source, entry.Original)
+		return err == nil && same
+	}
+	if info.Mode()&os.ModeSymlink == 0 {
+		return false
+	}
+	dest, err := os.Readlink(entry.Original)
+	return err == nil && dest == entry.Source
+}
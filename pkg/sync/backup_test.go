@@ -0,0 +1,112 @@
+package sync
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/morganhein/envy/pkg/io"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackupAndRestore(t *testing.T) {
+	source, target, backupDir := t.TempDir(), t.TempDir(), t.TempDir()
+	writeFiles(t, source, map[string]string{"a.conf": "source a", "nvim/init.lua": "source init"})
+	writeFiles(t, target, map[string]string{"a.conf": "target a", "nvim/init.lua": "target init"})
+	before := snapshot(t, target)
+
+	term := &scriptedTerminal{Logger: io.NewLogger()}
+	ops := newFileOps(false, nil)
+	now := time.Date(2021, 10, 3, 12, 30, 0, 0, time.UTC)
+	backups := newBackupStore(backupDir, target, ops, now)
+	config := SyncConfig{Source: source, Target: target, term: term, ops: ops}
+	config.syncer = NewSyncer(io.NewFilesystem(), term, ops, backups, BackupAndLink, "")
+
+	summary, err := syncHelper(context.Background(), config)
+	require.NoError(t, err)
+	assert.Len(t, summary.BackedUp, 2)
+	// the backups mirror the paths in the target
+	body, err := os.ReadFile(filepath.Join(backupDir, "20211003-123000", "nvim", "init.lua"))
+	assert.NoError(t, err)
+	assert.Equal(t, "target init", string(body))
+	manifests, err := ListBackups(backupDir)
+	require.NoError(t, err)
+	require.Len(t, manifests, 1)
+	assert.Equal(t, []BackupEntry{
+		{Original: filepath.Join(target, "a.conf"), Backup: "a.conf", Source: filepath.Join(source, "a.conf"), Mode: LinkMode},
+		{Original: filepath.Join(target, "nvim/init.lua"), Backup: "nvim/init.lua", Source: filepath.Join(source, "nvim/init.lua"), Mode: LinkMode},
+	}, manifests[0].Entries)
+
+	// a second run in the same second doesn't mix its backups with the first
+	assert.Equal(t, filepath.Join(backupDir, "20211003-123000-1"), newBackupStore(backupDir, target, ops, now).dir)
+
+	t.Run("files that changed since are not restored", func(t *testing.T) {
+		link := filepath.Join(target, "a.conf")
+		require.NoError(t, os.Remove(link))
+		require.NoError(t, os.WriteFile(link, []byte("changed"), 0644))
+		err := restoreHelper(backupDir, "", newFileOps(false, nil), io.NewLogger())
+		assert.NoError(t, err)
+		body, err := os.ReadFile(link)
+		assert.NoError(t, err)
+		assert.Equal(t, "changed", string(body))
+		require.NoError(t, os.Remove(link))
+		require.NoError(t, os.Symlink(filepath.Join(source, "a.conf"), link))
+	})
+
+	t.Run("links that point elsewhere are not restored", func(t *testing.T) {
+		link := filepath.Join(target, "a.conf")
+		elsewhere := filepath.Join(t.TempDir(), "a.conf")
+		require.NoError(t, os.Remove(link))
+		require.NoError(t, os.Symlink(elsewhere, link))
+		err := restoreHelper(backupDir, "", newFileOps(false, nil), io.NewLogger())
+		assert.NoError(t, err)
+		dest, err := os.Readlink(link)
+		assert.NoError(t, err)
+		assert.Equal(t, elsewhere, dest)
+		require.NoError(t, os.Remove(link))
+		require.NoError(t, os.Symlink(filepath.Join(source, "a.conf"), link))
+	})
+
+	t.Run("restore", func(t *testing.T) {
+		assert.Error(t, restoreHelper(backupDir, "20200101-000000", newFileOps(false, nil), io.NewLogger()))
+		err := restoreHelper(backupDir, "20211003-123000", newFileOps(false, nil), io.NewLogger())
+		require.NoError(t, err)
+		assert.Equal(t, before, snapshot(t, target))
+		manifests, err := ListBackups(backupDir)
+		assert.NoError(t, err)
+		assert.Empty(t, manifests)
+	})
+}
+
+func TestBackupAndRestoreCopies(t *testing.T) {
+	source, target, backupDir := t.TempDir(), t.TempDir(), t.TempDir()
+	writeFiles(t, source, map[string]string{"a.conf": "source a", "b.conf": "source b"})
+	writeFiles(t, target, map[string]string{"a.conf": "target a", "b.conf": "target b"})
+	before := snapshot(t, target)
+
+	term := &scriptedTerminal{Logger: io.NewLogger()}
+	ops := newFileOps(false, nil)
+	backups := newBackupStore(backupDir, target, ops, time.Now())
+	config := SyncConfig{Source: source, Target: target, Mode: CopyMode, term: term, ops: ops}
+	config.syncer = NewSyncer(io.NewFilesystem(), term, ops, backups, BackupAndLink, CopyMode)
+
+	summary, err := syncHelper(context.Background(), config)
+	require.NoError(t, err)
+	assert.Len(t, summary.BackedUp, 2)
+	// a copy that was edited since is left alone
+	require.NoError(t, os.WriteFile(filepath.Join(target, "b.conf"), []byte("edited"), 0644))
+
+	require.NoError(t, restoreHelper(backupDir, "", newFileOps(false, nil), io.NewLogger()))
+	files := snapshot(t, target)
+	assert.Equal(t, before[filepath.Join(target, "a.conf")], files[filepath.Join(target, "a.conf")])
+	assert.Equal(t, "edited", files[filepath.Join(target, "b.conf")])
+	manifests, err := ListBackups(backupDir)
+	require.NoError(t, err)
+	require.Len(t, manifests, 1)
+	assert.Equal(t, []BackupEntry{
+		{Original: filepath.Join(target, "b.conf"), Backup: "b.conf", Source: filepath.Join(source, "b.conf"), Mode: CopyMode},
+	}, manifests[0].Entries)
+}
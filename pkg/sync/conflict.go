@@ -0,0 +1,217 @@
+package sync
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/AlecAivazis/survey/v2"
+	"golang.org/x/xerrors"
+)
+
+// ConflictAction is what to do about a file in the target that isn't linked from the source
+type ConflictAction string
+
+const (
+	BackupAndLink ConflictAction = "backup" // back up the file in the target, and link the source in its place
+	Adopt         ConflictAction = "adopt"  // move the file in the target into the source, and link it back
+	Ignore        ConflictAction = "ignore" // add the file to the .envyignore of the source, so it's never synced
+	Skip          ConflictAction = "skip"   // leave the file alone, until the next sync
+	showDiff      ConflictAction = "diff"   // only offered when prompting
+)
+
+// ignoreFile lists the files in the source that are never synced, as patterns in the gitignore format
+const ignoreFile = ".envyignore"
+
+var conflictLabels = map[ConflictAction]string{
+	BackupAndLink: "back up the target and link the source",
+	Adopt:         "adopt the target into the source",
+	Ignore:        "ignore it from now on",
+	Skip:          "skip it for now",
+	showDiff:      "show the differences",
+}
+
+// ParseConflictAction parses the action given on the command line
+func ParseConflictAction(action string) (ConflictAction, error) {
+	switch a := ConflictAction(strings.ToLower(action)); a {
+	case "":
+		return "", nil
+	case BackupAndLink, Adopt, Ignore, Skip:
+		return a, nil
+	}
+	return "", xerrors.Errorf("unknown conflict action `%v`, expected one of backup, adopt, ignore or skip", action)
+}
+
+// conflictActions are the actions that make sense for the issue
+func conflictActions(issue FileMismatchIssue) []ConflictAction {
+	if issue == MissingFromSource {
+		// there is nothing in the source to link or compare against
+		return []ConflictAction{Adopt, Ignore, Skip}
+	}
+	if issue == DirCollision {
+		return []ConflictAction{BackupAndLink, Adopt, Ignore, Skip}
+	}
+	return []ConflictAction{BackupAndLink, Adopt, Ignore, Skip, showDiff}
+}
+
+// Prompts the user on what course of action to perform on a file conflict:
+// 1. Rename target and move to a backup, symlink source to target
+// 2. Move the target into the source, and symlink it back
+// 3. Ignore file and ignore symlink from now on.
+// 4. Leave it alone for now
+// The differences between the files can be shown before deciding. An action can be repeated for the remaining
+// conflicts of the same kind, and when a policy is set, it's used without prompting. The policy doesn't apply to
+// files that are only in the target, which are skipped.
+func (s *syncer) ResolveFileConflict(ctx context.Context, m Mismatch, remaining int) (ConflictAction, error) {
+	actions := conflictActions(m.Issue)
+	allowed := func(action ConflictAction) bool {
+		for _, a := range actions {
+			if a == action {
+				return true
+			}
+		}
+		return false
+	}
+	if action, ok := s.repeat[m.Issue]; ok {
+		return action, nil
+	}
+	if len(s.policy) > 0 {
+		// files that are only in the target are never adopted without asking, they could be anything
+		if m.Issue == MissingFromSource || !allowed(s.policy) {
+			return Skip, nil
+		}
+		return s.policy, nil
+	}
+
+	labels := make([]string, 0, len(actions))
+	for _, a := range actions {
+		labels = append(labels, conflictLabels[a])
+	}
+	for {
+		selected, err := s.term.PromptUser(fmt.Sprintf("%v: %v", m.Issue, m.To), labels, labels[0])
+		if err != nil {
+			return "", err
+		}
+		var action ConflictAction
+		for a, label := range conflictLabels {
+			if label == selected {
+				action = a
+			}
+		}
+		if !allowed(action) {
+			return "", xerrors.Errorf("unexpected selection `%v`", selected)
+		}
+		if action == showDiff {
+			if err = printDiff(os.Stdout, m.From, m.To); err != nil {
+				return "", err
+			}
+			continue
+		}
+		if remaining > 0 {
+			all := false
+			prompt := &survey.Confirm{
+				Message: fmt.Sprintf("Do the same for the %d remaining files that are %v?", remaining, m.Issue),
+			}
+			if err = s.term.AskOne(prompt, &all); err != nil {
+				return "", err
+			}
+			if all {
+				s.repeat[m.Issue] = action
+			}
+		}
+		return action, nil
+	}
+}
+
+// Adopt moves the file in the target into the source, replacing what is there, and links or copies it back. The
+// file it replaces is backed up.
+func (s *syncer) Adopt(from, to string) error {
+	if info, err := os.Lstat(to); err == nil && info.IsDir() {
+		if _, err = os.Stat(from); err == nil {
+			return s.adoptDir(from, to)
+		}
+	}
+	if err := s.backupSource(from); err != nil {
+		return err
+	}
+	if err := s.ops.Rename(to, from); err != nil {
+		return xerrors.Errorf("error moving %v into the source: %w", to, err)
+	}
+	if s.mode == CopyMode {
+		return s.ops.Copy(from, to)
+	}
+	return s.ops.Symlink(from, to)
+}
+
+// adoptDir moves the files of the directory in the target into the same directory in the source, backing up and
+// replacing what is there, and links the directory back. What is left of the target, the directories and links, is
+// backed up.
+func (s *syncer) adoptDir(from, to string) error {
+	err := filepath.WalkDir(to, func(p string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		relative, err := filepath.Rel(to, p)
+		if err != nil {
+			return err
+		}
+		source := filepath.Join(from, relative)
+		if link, err := os.Readlink(p); err == nil && filepath.Clean(link) == source {
+			return nil
+		}
+		if err = s.backupSource(source); err != nil {
+			return err
+		}
+		if err = s.ops.Rename(p, source); err != nil {
+			return xerrors.Errorf("error moving %v into the source: %w", p, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return s.Place(from, to)
+}
+
+// backupSource backs up the file of the source that an adopted file replaces, since it may not be committed
+// anywhere
+func (s *syncer) backupSource(path string) error {
+	if _, err := os.Lstat(path); os.IsNotExist(err) {
+		return nil
+	}
+	backup, err := s.backups.Backup(path, "", "")
+	if err != nil {
+		return err
+	}
+	s.term.Infof("backed up %v to %v, before replacing it with the adopted file", path, backup)
+	return nil
+}
+
+// IgnorePermanently adds the path, relative to the source, to the .envyignore of the source. It is anchored
+// and escaped, so only that path is ignored.
+func (s *syncer) IgnorePermanently(source, relative string) error {
+	return s.ops.AppendLine(filepath.Join(source, ignoreFile), "/"+escapeIgnorePattern(filepath.ToSlash(relative)))
+}
+
+// loadIgnoreFile reads the patterns in the .envyignore of the source, if it has one
+func loadIgnoreFile(source string) ([]string, error) {
+	f, err := os.Open(filepath.Join(source, ignoreFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, xerrors.Errorf("error reading %v: %v", ignoreFile, err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	var ignores []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		ignores = append(ignores, scanner.Text())
+	}
+	return ignores, scanner.Err()
+}
@@ -0,0 +1,153 @@
+package sync
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/morganhein/envy/pkg/io"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// scriptedTerminal answers prompts from a script, and keeps every directory in sync
+type scriptedTerminal struct {
+	io.Logger
+	answers []string
+	prompts []string
+	repeat  bool
+}
+
+func (s *scriptedTerminal) PromptUser(prompt string, options []string, defaultSelection string) (string, error) {
+	s.prompts = append(s.prompts, prompt)
+	answer := s.answers[0]
+	s.answers = s.answers[1:]
+	return answer, nil
+}
+
+func (s *scriptedTerminal) AskOne(p survey.Prompt, response interface{}, opts ...survey.AskOpt) error {
+	switch p := p.(type) {
+	case *survey.MultiSelect:
+		*response.(*[]string) = p.Options
+	case *survey.Confirm:
+		*response.(*bool) = s.repeat
+	}
+	return nil
+}
+
+func (s *scriptedTerminal) Progress(p io.Progress) {}
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	for name, contents := range files {
+		name = filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(name), 0755))
+		require.NoError(t, os.WriteFile(name, []byte(contents), 0644))
+	}
+}
+
+func TestSyncResolvesConflicts(t *testing.T) {
+	source, target := t.TempDir(), t.TempDir()
+	writeFiles(t, source, map[string]string{"a.conf": "source a", "b.conf": "source b", "dir/c.conf": "source c"})
+	writeFiles(t, target, map[string]string{"a.conf": "target a", "dir/c.conf": "target c", "d.conf": "target d"})
+	term := &scriptedTerminal{
+		Logger:  io.NewLogger(),
+		answers: []string{conflictLabels[showDiff], conflictLabels[Ignore], conflictLabels[Adopt], conflictLabels[Skip]},
+	}
+	config := SyncConfig{Source: source, Target: target, Untracked: true, term: term, ops: newFileOps(false, nil)}
+	backupDir := t.TempDir()
+	backups := newBackupStore(backupDir, target, config.ops, time.Now())
+	config.syncer = NewSyncer(io.NewFilesystem(), term, config.ops, backups, "", "")
+
+	summary, err := syncHelper(context.Background(), config)
+	require.NoError(t, err)
+	assert.Equal(t, Summary{
+		Created: []string{filepath.Join(target, "b.conf")},
+		Adopted: []string{filepath.Join(target, "dir/c.conf")},
+		Ignored: []string{filepath.Join(target, "a.conf")},
+		Skipped: []string{filepath.Join(target, "d.conf")},
+	}, *summary)
+	assert.Equal(t, []string{
+		"file collision: " + filepath.Join(target, "a.conf"),
+		"file collision: " + filepath.Join(target, "a.conf"),
+		"file collision: " + filepath.Join(target, "dir/c.conf"),
+		"missing from source: " + filepath.Join(target, "d.conf"),
+	}, term.prompts)
+
+	// missing files are linked
+	linked, err := io.NewFilesystem().IsSymlinkTo(filepath.Join(target, "b.conf"), filepath.Join(source, "b.conf"))
+	assert.NoError(t, err)
+	assert.True(t, linked)
+	// ignored files are left alone, and listed in the .envyignore
+	body, err := os.ReadFile(filepath.Join(target, "a.conf"))
+	assert.NoError(t, err)
+	assert.Equal(t, "target a", string(body))
+	body, err = os.ReadFile(filepath.Join(source, ignoreFile))
+	assert.NoError(t, err)
+	assert.Equal(t, "/a.conf\n", string(body))
+	// adopted files replace the source, which is backed up, and are linked back
+	body, err = os.ReadFile(filepath.Join(source, "dir/c.conf"))
+	assert.NoError(t, err)
+	assert.Equal(t, "target c", string(body))
+	body, err = os.ReadFile(filepath.Join(backups.dir, source, "dir/c.conf"))
+	assert.NoError(t, err)
+	assert.Equal(t, "source c", string(body))
+	// restoring leaves the adopted file in the source
+	require.NoError(t, restoreHelper(backupDir, "", newFileOps(false, nil), io.NewLogger()))
+	body, err = os.ReadFile(filepath.Join(source, "dir/c.conf"))
+	assert.NoError(t, err)
+	assert.Equal(t, "target c", string(body))
+	linked, err = io.NewFilesystem().IsSymlinkTo(filepath.Join(target, "dir/c.conf"), filepath.Join(source, "dir/c.conf"))
+	assert.NoError(t, err)
+	assert.True(t, linked)
+
+	t.Run("ignored files are not asked about again, and policies don't prompt", func(t *testing.T) {
+		term.prompts = nil
+		config.syncer = NewSyncer(io.NewFilesystem(), term, config.ops, backups, Adopt, "")
+		summary, err := syncHelper(context.Background(), config)
+		require.NoError(t, err)
+		assert.Empty(t, term.prompts)
+		// files that are only in the target are never adopted by a policy
+		assert.Equal(t, []string{filepath.Join(target, "d.conf")}, summary.Skipped)
+		_, err = os.Stat(filepath.Join(source, "d.conf"))
+		assert.True(t, os.IsNotExist(err))
+	})
+
+	t.Run("files that are only in the target aren't found unless asked for", func(t *testing.T) {
+		term.prompts = nil
+		config.Untracked = false
+		config.syncer = NewSyncer(io.NewFilesystem(), term, config.ops, nil, "", "")
+		summary, err := syncHelper(context.Background(), config)
+		require.NoError(t, err)
+		assert.Empty(t, term.prompts)
+		assert.Equal(t, Summary{}, *summary)
+	})
+}
+
+func TestResolveFileConflictRepeats(t *testing.T) {
+	term := &scriptedTerminal{Logger: io.NewLogger(), answers: []string{conflictLabels[Skip]}, repeat: true}
+	s := NewSyncer(io.NewFilesystem(), term, nil, nil, "", "")
+	for remaining := 2; remaining >= 0; remaining-- {
+		action, err := s.ResolveFileConflict(context.Background(), Mismatch{Issue: FileCollision}, remaining)
+		assert.NoError(t, err)
+		assert.Equal(t, Skip, action)
+	}
+	assert.Len(t, term.prompts, 1)
+
+	// a policy that doesn't apply to the issue skips it, and files only in the target are never adopted by one
+	for _, policy := range []ConflictAction{BackupAndLink, Adopt} {
+		s = NewSyncer(io.NewFilesystem(), term, nil, nil, policy, "")
+		action, err := s.ResolveFileConflict(context.Background(), Mismatch{Issue: MissingFromSource}, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, Skip, action)
+	}
+}
+
+func TestDiffLines(t *testing.T) {
+	a := []string{"a\n", "b\n", "c\n", "d\n"}
+	b := []string{"a\n", "c\n", "d\n", "e\n"}
+	assert.Equal(t, []string{" a\n", "-b\n", " c\n", " d\n", "+e\n"}, diffLines(a, b))
+	assert.Equal(t, []string{"+a\n"}, diffLines(nil, []string{"a\n"}))
+}
@@ -0,0 +1,94 @@
+package sync
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/xerrors"
+)
+
+// maxDiffLines keeps the diff from using too much memory, which grows with the product of the file lengths
+const maxDiffLines = 5000
+
+// printDiff prints the line differences between the file in the source and the file in the target
+func printDiff(out io.Writer, from, to string) error {
+	a, err := os.ReadFile(from)
+	if err != nil {
+		return xerrors.Errorf("error reading %v: %v", from, err)
+	}
+	b, err := os.ReadFile(to)
+	if err != nil {
+		return xerrors.Errorf("error reading %v: %v", to, err)
+	}
+	if bytes.IndexByte(a, 0) >= 0 || bytes.IndexByte(b, 0) >= 0 {
+		_, err = fmt.Fprintln(out, "binary files differ")
+		return err
+	}
+	aLines, bLines := splitLines(a), splitLines(b)
+	if len(aLines) > maxDiffLines || len(bLines) > maxDiffLines {
+		_, err = fmt.Fprintln(out, "the files are too large to compare")
+		return err
+	}
+	_, err = fmt.Fprintf(out, "--- %v\n+++ %v\n%v", from, to, strings.Join(diffLines(aLines, bLines), ""))
+	return err
+}
+
+func splitLines(b []byte) []string {
+	if len(b) == 0 {
+		return nil
+	}
+	lines := strings.SplitAfter(string(b), "\n")
+	if last := lines[len(lines)-1]; len(last) == 0 {
+		lines = lines[:len(lines)-1]
+	} else if !strings.HasSuffix(last, "\n") {
+		lines[len(lines)-1] = last + "\n"
+	}
+	return lines
+}
+
+// diffLines compares the lines using their longest common subsequence. Lines only in a are prefixed with "-",
+// lines only in b with "+", and lines in both with " ".
+func diffLines(a, b []string) []string {
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	var diff []string
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			diff = append(diff, " "+a[i])
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			diff = append(diff, "-"+a[i])
+			i++
+		default:
+			diff = append(diff, "+"+b[j])
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		diff = append(diff, "-"+a[i])
+	}
+	for ; j < len(b); j++ {
+		diff = append(diff, "+"+b[j])
+	}
+	return diff
+}
@@ -1,5 +1,13 @@
 package sync
 
+import (
+	"path"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/xerrors"
+)
+
 func determineLeftOuterUnion(left, right []string) []string {
 	var result []string
 	exists := func(needle string, haystack []string) bool {
@@ -17,3 +25,181 @@ func determineLeftOuterUnion(left, right []string) []string {
 	}
 	return result
 }
+
+// defaultIgnores are always ignored, before the ignores of the config, the recipe and the .envyignore
+var defaultIgnores = []string{".git/", "/" + ignoreFile}
+
+// ignorePattern is a pattern in the gitignore format
+type ignorePattern struct {
+	segments []string // the pattern split on "/", where "**" matches any number of directories
+	negate   bool     // the pattern re-includes what an earlier pattern ignored
+	dirOnly  bool     // the pattern only matches directories
+}
+
+// ignoreMatcher matches paths relative to the source or target against ignore patterns. Like gitignore, the
+// last pattern that matches decides, and nothing inside an ignored directory can be re-included.
+type ignoreMatcher struct {
+	patterns []ignorePattern
+}
+
+// compileIgnores parses the patterns, skipping blank lines and comments. The matcher holds every valid
+// pattern even when an error is returned.
+func compileIgnores(lines []string) (*ignoreMatcher, error) {
+	m := &ignoreMatcher{}
+	var firstErr error
+	for _, line := range lines {
+		p, ok, err := parseIgnorePattern(line)
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+		if ok && err == nil {
+			m.patterns = append(m.patterns, p)
+		}
+	}
+	return m, firstErr
+}
+
+func parseIgnorePattern(line string) (ignorePattern, bool, error) {
+	p := ignorePattern{}
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return p, false, nil
+	}
+	switch {
+	case strings.HasPrefix(line, `\#`), strings.HasPrefix(line, `\!`):
+		line = line[1:]
+	case strings.HasPrefix(line, "!"):
+		p.negate = true
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		p.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if line == "" {
+		return p, false, nil
+	}
+	// a pattern with a slash is relative to the root, otherwise it matches at any depth
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+	p.segments = strings.Split(line, "/")
+	for _, s := range p.segments {
+		if _, err := path.Match(s, ""); err != nil {
+			return p, false, xerrors.Errorf("invalid ignore pattern `%v`: %v", line, err)
+		}
+	}
+	if !anchored {
+		p.segments = append([]string{"**"}, p.segments...)
+	}
+	return p, true, nil
+}
+
+// Match reports whether the path, relative to the root and using either separator, is ignored
+func (m *ignoreMatcher) Match(relative string, isDir bool) bool {
+	if m == nil || len(m.patterns) == 0 {
+		return false
+	}
+	parts := strings.Split(filepath.ToSlash(filepath.Clean(relative)), "/")
+	// a path inside an ignored directory is ignored, whatever the patterns say about the path itself
+	for i := 1; i < len(parts); i++ {
+		if m.matchPath(parts[:i], true) {
+			return true
+		}
+	}
+	return m.matchPath(parts, isDir)
+}
+
+func (m *ignoreMatcher) matchPath(parts []string, isDir bool) bool {
+	ignored := false
+	for _, p := range m.patterns {
+		if p.dirOnly && !isDir {
+			continue
+		}
+		if matchSegments(p.segments, parts) {
+			ignored = !p.negate
+		}
+	}
+	return ignored
+}
+
+func matchSegments(pattern, parts []string) bool {
+	if len(pattern) == 0 {
+		return len(parts) == 0
+	}
+	if pattern[0] == "**" {
+		// a trailing "**" matches everything inside, but not the directory itself
+		if len(pattern) == 1 {
+			return len(parts) > 0
+		}
+		for i := 0; i <= len(parts); i++ {
+			if matchSegments(pattern[1:], parts[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(parts) == 0 {
+		return false
+	}
+	if ok, _ := path.Match(pattern[0], parts[0]); !ok {
+		return false
+	}
+	return matchSegments(pattern[1:], parts[1:])
+}
+
+// escapeIgnorePattern escapes the glob characters of a path, so it is ignored literally
+func escapeIgnorePattern(relative string) string {
+	var b strings.Builder
+	for _, r := range relative {
+		if strings.ContainsRune(`\*?[`, r) {
+			b.WriteRune('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// dirIgnores returns the patterns that ignore every directory except dirs, and what is beside the directories they
+// are in. The files at the top of the source and target are still synced. A dir of "." syncs every directory.
+func dirIgnores(dirs []string) []string {
+	cleaned := make([]string, 0, len(dirs))
+	for _, d := range dirs {
+		d = strings.Trim(filepath.ToSlash(filepath.Clean(d)), "/")
+		if d == "." || d == "" {
+			return nil
+		}
+		cleaned = append(cleaned, d)
+	}
+	// everything in a directory is synced, so the directories inside it don't narrow anything
+	within := func(d string) bool {
+		for _, other := range cleaned {
+			if strings.HasPrefix(d, other+"/") {
+				return true
+			}
+		}
+		return false
+	}
+	// the patterns that ignore only match at their own depth, so they all go before the ones that re-include
+	ignores := []string{"/*/"}
+	var includes []string
+	seen := map[string]bool{}
+	add := func(list *[]string, pattern string) {
+		if !seen[pattern] {
+			seen[pattern] = true
+			*list = append(*list, pattern)
+		}
+	}
+	for _, d := range cleaned {
+		if within(d) {
+			continue
+		}
+		parts := strings.Split(d, "/")
+		for i := range parts {
+			if i > 0 {
+				add(&ignores, "/"+escapeIgnorePattern(strings.Join(parts[:i], "/"))+"/*")
+			}
+			add(&includes, "!/"+escapeIgnorePattern(strings.Join(parts[:i+1], "/")))
+		}
+	}
+	return append(ignores, includes...)
+}
@@ -0,0 +1,119 @@
+package sync
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/morganhein/envy/pkg/io"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIgnoreMatcher(t *testing.T) {
+	tests := []struct {
+		patterns []string
+		path     string
+		isDir    bool
+		ignored  bool
+	}{
+		{[]string{"*.swp"}, ".vimrc.swp", false, true},
+		{[]string{"*.swp"}, "nvim/init.lua.swp", false, true},
+		{[]string{"*.swp"}, ".vimrc", false, false},
+		{[]string{".git/"}, ".git", true, true},
+		{[]string{".git/"}, ".git", false, false},
+		{[]string{".git/"}, "nvim/.git/config", false, true},
+		{[]string{"**/node_modules"}, "a/b/node_modules", true, true},
+		{[]string{"**/node_modules"}, "node_modules", true, true},
+		{[]string{"/.zshrc"}, ".zshrc", false, true},
+		{[]string{"/.zshrc"}, "zsh/.zshrc", false, false},
+		{[]string{"nvim/*.lua"}, "nvim/init.lua", false, true},
+		{[]string{"nvim/*.lua"}, "nvim/lua/init.lua", false, false},
+		{[]string{"nvim/**/*.lua"}, "nvim/lua/plugins/init.lua", false, true},
+		{[]string{"nvim/**"}, "nvim", true, false},
+		{[]string{"nvim/**"}, "nvim/init.lua", false, true},
+		{[]string{"*.conf", "!keep.conf"}, "keep.conf", false, false},
+		{[]string{"*.conf", "!keep.conf"}, "other.conf", false, true},
+		{[]string{"!keep.conf", "*.conf"}, "keep.conf", false, true},
+		// nothing inside an ignored directory can be re-included
+		{[]string{"cache/", "!cache/keep"}, "cache/keep", false, true},
+		{[]string{"# comment", "", `\#notes`}, "#notes", false, true},
+		{[]string{"# comment"}, "# comment", false, false},
+		{[]string{`/\*literal`}, "*literal", false, true},
+		{[]string{`/\*literal`}, "aliteral", false, false},
+	}
+	for _, test := range tests {
+		m, err := compileIgnores(test.patterns)
+		require.NoError(t, err)
+		assert.Equal(t, test.ignored, m.Match(filepath.FromSlash(test.path), test.isDir), "%v matching %v", test.patterns, test.path)
+	}
+
+	_, err := compileIgnores([]string{"[", "*.swp"})
+	assert.Error(t, err)
+}
+
+func TestSyncIgnores(t *testing.T) {
+	source, target := t.TempDir(), t.TempDir()
+	writeFiles(t, source, map[string]string{
+		".git/config":     "git",
+		".vimrc":          "vimrc",
+		".vimrc.swp":      "swap",
+		"nvim/init.lua":   "init",
+		"nvim/plugin.lua": "plugin",
+		ignoreFile:        "# local only\nnvim/*.lua\n!nvim/init.lua\n",
+	})
+	writeFiles(t, target, map[string]string{"node_modules/pkg/index.js": "js"})
+	term := &scriptedTerminal{Logger: io.NewLogger()}
+	config := SyncConfig{Source: source, Target: target, Ignores: []string{"*.swp", "node_modules/"}, term: term, ops: newFileOps(false, nil)}
+	config.syncer = NewSyncer(io.NewFilesystem(), term, config.ops, nil, Skip, "")
+
+	summary, err := syncHelper(context.Background(), config)
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(target, ".vimrc"), filepath.Join(target, "nvim/init.lua")}, summary.Created)
+	assert.Empty(t, summary.Skipped)
+}
+
+func TestSyncDirs(t *testing.T) {
+	source, target := t.TempDir(), t.TempDir()
+	writeFiles(t, source, map[string]string{
+		".zshrc":                 "zshrc",
+		".config/nvim/init.lua":  "init",
+		".config/fish/config":    "fish",
+		".config/starship.toml":  "starship",
+		"scripts/backup.sh":      "backup",
+		"scripts/bin/install.sh": "install",
+	})
+	writeFiles(t, target, map[string]string{".cache/thumbnail": "cache"})
+	term := &scriptedTerminal{Logger: io.NewLogger()}
+	config := SyncConfig{Source: source, Target: target, Dirs: []string{".config/nvim", "scripts", "scripts/bin"}, term: term, ops: newFileOps(false, nil)}
+	config.syncer = NewSyncer(io.NewFilesystem(), term, config.ops, nil, Skip, "")
+
+	summary, err := syncHelper(context.Background(), config)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		filepath.Join(target, ".config/nvim/init.lua"),
+		filepath.Join(target, ".zshrc"),
+		filepath.Join(target, "scripts/backup.sh"),
+		filepath.Join(target, "scripts/bin/install.sh"),
+	}, summary.Created)
+	assert.Empty(t, summary.Skipped)
+}
+
+func TestSelectDirs(t *testing.T) {
+	source, target := t.TempDir(), t.TempDir()
+	writeFiles(t, source, map[string]string{"nvim/init.lua": "init"})
+	writeFiles(t, target, map[string]string{"nvim/init.lua": "init", "cache/thumbnail": "cache"})
+	term := &scriptedTerminal{Logger: io.NewLogger()}
+	config := SyncConfig{Source: source, Target: target, term: term, interactive: true}
+	config.syncer = NewSyncer(io.NewFilesystem(), term, nil, nil, "", "")
+
+	// the picker offers the directories of the target too
+	dirs, err := selectDirs(context.Background(), config)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"cache", "nvim"}, dirs)
+
+	config.AllDirs = true
+	dirs, err = selectDirs(context.Background(), config)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"nvim"}, dirs)
+}
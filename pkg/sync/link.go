@@ -0,0 +1,94 @@
+package sync
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/morganhein/envy/pkg/io"
+	"golang.org/x/term"
+	"golang.org/x/xerrors"
+)
+
+// Links are single files or directories linked into place, such as by the link step of a task. A file that is
+// already where a link goes is a conflict, which is resolved just like the conflicts of a sync.
+
+// Link links From, in the source, to To
+type Link struct {
+	From string
+	To   string
+}
+
+type LinkConfig struct {
+	Source     string // the links are ignored when the .envyignore of the source ignores them
+	Target     string // the backups are kept relative to the target
+	Links      []Link
+	DryRun     bool
+	OnConflict ConflictAction // OnConflict resolves every conflict the same way, instead of prompting
+}
+
+// CreateLinks creates the links, and prints a summary of what was done
+func CreateLinks(config LinkConfig) error {
+	sc := SyncConfig{
+		Source:     config.Source,
+		Target:     config.Target,
+		DryRun:     config.DryRun,
+		OnConflict: config.OnConflict,
+		term:       io.NewTerminal(),
+	}
+	if len(sc.OnConflict) == 0 && !term.IsTerminal(int(os.Stdin.Fd())) {
+		sc.term.Warningf("not running in a terminal, so links that conflict are skipped. Set on_conflict in the [sync] section to resolve them.")
+		sc.OnConflict = Skip
+	}
+	sc.ops = newFileOps(config.DryRun, os.Stdout)
+	backupDir, err := DefaultBackupDir()
+	if err != nil {
+		return err
+	}
+	backups := newBackupStore(backupDir, config.Target, sc.ops, time.Now())
+	sc.syncer = NewSyncer(io.NewFilesystem(), sc.term, sc.ops, backups, sc.OnConflict, LinkMode)
+	summary, err := linkHelper(context.Background(), sc, config.Links)
+	if err != nil {
+		return err
+	}
+	summary.BackupDir = backups.dir
+	return sc.ops.printSummary(*summary)
+}
+
+func linkHelper(ctx context.Context, config SyncConfig, links []Link) (*Summary, error) {
+	ignoredFiles, err := loadIgnoreFile(config.Source)
+	if err != nil {
+		return nil, err
+	}
+	matcher, err := compileIgnores(append(append([]string{}, defaultIgnores...), ignoredFiles...))
+	if err != nil {
+		return nil, err
+	}
+	fs := io.NewFilesystem()
+	var mismatches []Mismatch
+	for _, l := range links {
+		info, err := os.Stat(l.From)
+		if err != nil {
+			return nil, xerrors.Errorf("unable to link %v: %w", l.From, err)
+		}
+		relative, err := filepath.Rel(config.Source, l.From)
+		if err == nil && !strings.HasPrefix(relative, "..") && matcher.Match(relative, info.IsDir()) {
+			config.term.Infof("not linking %v, which is ignored", l.From)
+			continue
+		}
+		if _, err = os.Lstat(l.To); os.IsNotExist(err) {
+			mismatches = append(mismatches, Mismatch{From: l.From, To: l.To, Issue: MissingFromTarget})
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if linked, err := fs.IsSymlinkTo(l.To, l.From); err == nil && linked {
+			continue
+		}
+		mismatches = append(mismatches, Mismatch{From: l.From, To: l.To, Issue: FileCollision})
+	}
+	return resolveMismatches(ctx, config, mismatches)
+}
@@ -0,0 +1,86 @@
+package sync
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/morganhein/envy/pkg/io"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSyncLinksDirectories(t *testing.T) {
+	source, target := t.TempDir(), t.TempDir()
+	writeFiles(t, source, map[string]string{
+		".zshrc":            "zshrc",
+		"nvim/init.lua":     "init",
+		"nvim/lua/a.lua":    "a",
+		"fish/config.fish":  "fish",
+		"fish/.git/HEAD":    "ref",
+		"tmux/tmux.conf":    "tmux",
+		"vim/vimrc":         "source vimrc",
+		"scripts/backup.sh": "backup",
+	})
+	writeFiles(t, target, map[string]string{
+		"vim/vimrc":               "target vimrc",
+		"vim/local.vim":           "local",
+		"alacritty/alacritty.yml": "alacritty",
+	})
+	// a directory that was linked file by file before
+	require.NoError(t, os.MkdirAll(filepath.Join(target, "tmux"), 0755))
+	require.NoError(t, os.Symlink(filepath.Join(source, "tmux/tmux.conf"), filepath.Join(target, "tmux/tmux.conf")))
+
+	term := &scriptedTerminal{Logger: io.NewLogger()}
+	config := SyncConfig{
+		Source:    source,
+		Target:    target,
+		Dirs:      []string{"nvim", "fish", "tmux", "vim", "scripts", "alacritty"},
+		DirLinks:  DirLinks{All: true, Dirs: map[string]bool{"scripts/": false}},
+		Untracked: true,
+		term:      term,
+		ops:       newFileOps(false, nil),
+	}
+	backups := newBackupStore(t.TempDir(), target, config.ops, time.Now())
+	config.syncer = NewSyncer(io.NewFilesystem(), term, config.ops, backups, Adopt, "")
+
+	summary, err := syncHelper(context.Background(), config)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{
+		filepath.Join(target, ".zshrc"),
+		filepath.Join(target, "fish/config.fish"),
+		filepath.Join(target, "nvim"),
+		filepath.Join(target, "scripts/backup.sh"),
+	}, summary.Created)
+	// the directory of links is backed up before it's replaced
+	assert.Equal(t, []string{filepath.Join(target, "tmux")}, summary.BackedUp)
+	assert.Equal(t, []string{filepath.Join(target, "vim")}, summary.Adopted)
+	// the policy doesn't adopt the directory that is only in the target
+	assert.Equal(t, []string{filepath.Join(target, "alacritty")}, summary.Skipped)
+
+	fs := io.NewFilesystem()
+	for _, dir := range []string{"nvim", "tmux", "vim"} {
+		linked, err := fs.IsSymlinkTo(filepath.Join(target, dir), filepath.Join(source, dir))
+		assert.NoError(t, err)
+		assert.True(t, linked, dir)
+	}
+	// ignored files and overrides are linked file by file
+	for _, file := range []string{"fish/config.fish", "scripts/backup.sh"} {
+		linked, err := fs.IsSymlinkTo(filepath.Join(target, file), filepath.Join(source, file))
+		assert.NoError(t, err)
+		assert.True(t, linked, file)
+	}
+	// the files of an adopted directory are merged into the source
+	files := snapshot(t, source)
+	assert.Equal(t, "target vimrc", files[filepath.Join(source, "vim/vimrc")])
+	assert.Equal(t, "local", files[filepath.Join(source, "vim/local.vim")])
+	assert.NotContains(t, files, filepath.Join(source, "alacritty"))
+
+	t.Run("a second sync has nothing to do", func(t *testing.T) {
+		summary, err := syncHelper(context.Background(), config)
+		require.NoError(t, err)
+		assert.Equal(t, Summary{Skipped: []string{filepath.Join(target, "alacritty")}}, *summary)
+	})
+}
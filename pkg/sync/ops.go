@@ -0,0 +1,224 @@
+package sync
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/xerrors"
+)
+
+// OpKind is a kind of change sync makes to the filesystem
+type OpKind string
+
+const (
+	OpMkdir   OpKind = "mkdir"
+	OpSymlink OpKind = "symlink"
+	OpRename  OpKind = "rename"
+	OpCopy    OpKind = "copy"
+	OpAppend  OpKind = "append"
+	OpRemove  OpKind = "remove"
+)
+
+// Op is a change sync made, or would make in a dry run
+type Op struct {
+	Kind OpKind
+	From string // the source of a symlink, rename or copy, or the line appended to a file
+	To   string
+}
+
+func (o Op) String() string {
+	switch o.Kind {
+	case OpMkdir:
+		return fmt.Sprintf("create directory %v", o.To)
+	case OpSymlink:
+		return fmt.Sprintf("link %v -> %v", o.To, o.From)
+	case OpRename:
+		return fmt.Sprintf("move %v to %v", o.From, o.To)
+	case OpCopy:
+		return fmt.Sprintf("copy %v to %v", o.From, o.To)
+	case OpAppend:
+		return fmt.Sprintf("add %q to %v", o.From, o.To)
+	case OpRemove:
+		return fmt.Sprintf("remove %v", o.To)
+	}
+	return fmt.Sprintf("%v %v %v", o.Kind, o.From, o.To)
+}
+
+// fileOps makes every change sync makes to the filesystem, so that a dry run can record them instead
+type fileOps struct {
+	dryRun bool
+	out    io.Writer
+	ops    []Op
+	dirs   map[string]bool // directories created, or that would have been in a dry run
+}
+
+func newFileOps(dryRun bool, out io.Writer) *fileOps {
+	return &fileOps{dryRun: dryRun, out: out, dirs: map[string]bool{}}
+}
+
+// record notes the change, and returns if it should actually be made
+func (f *fileOps) record(op Op) bool {
+	f.ops = append(f.ops, op)
+	return !f.dryRun
+}
+
+func (f *fileOps) MkdirAll(dir string) error {
+	if f.dirs[dir] {
+		return nil
+	}
+	if info, err := os.Stat(dir); err == nil && info.IsDir() {
+		return nil
+	}
+	f.dirs[dir] = true
+	if !f.record(Op{Kind: OpMkdir, To: dir}) {
+		return nil
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return xerrors.Errorf("error creating directory %v: %v", dir, err)
+	}
+	return nil
+}
+
+func (f *fileOps) Symlink(from, to string) error {
+	if err := f.MkdirAll(filepath.Dir(to)); err != nil {
+		return err
+	}
+	if !f.record(Op{Kind: OpSymlink, From: from, To: to}) {
+		return nil
+	}
+	if err := os.Symlink(from, to); err != nil {
+		return xerrors.Errorf("error symlinking file: %v", err)
+	}
+	return nil
+}
+
+func (f *fileOps) Rename(from, to string) error {
+	if err := f.MkdirAll(filepath.Dir(to)); err != nil {
+		return err
+	}
+	if !f.record(Op{Kind: OpRename, From: from, To: to}) {
+		return nil
+	}
+	if err := os.Rename(from, to); err != nil {
+		return xerrors.Errorf("error moving %v to %v: %v", from, to, err)
+	}
+	return nil
+}
+
+// Remove removes a file, link or directory and everything in it
+func (f *fileOps) Remove(path string) error {
+	if !f.record(Op{Kind: OpRemove, To: path}) {
+		return nil
+	}
+	if err := os.RemoveAll(path); err != nil {
+		return xerrors.Errorf("error removing %v: %v", path, err)
+	}
+	return nil
+}
+
+// Copy copies the contents and mode of a file
+func (f *fileOps) Copy(from, to string) error {
+	if err := f.MkdirAll(filepath.Dir(to)); err != nil {
+		return err
+	}
+	if !f.record(Op{Kind: OpCopy, From: from, To: to}) {
+		return nil
+	}
+	in, err := os.Open(from)
+	if err != nil {
+		return xerrors.Errorf("error opening %v: %v", from, err)
+	}
+	defer func() {
+		_ = in.Close()
+	}()
+	info, err := in.Stat()
+	if err != nil {
+		return xerrors.Errorf("error reading %v: %v", from, err)
+	}
+	out, err := os.OpenFile(to, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return xerrors.Errorf("error creating %v: %v", to, err)
+	}
+	_, err = io.Copy(out, in)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return xerrors.Errorf("error copying %v to %v: %v", from, to, err)
+	}
+	return nil
+}
+
+// AppendLine adds a line to the end of the file, creating it if needed
+func (f *fileOps) AppendLine(file, line string) error {
+	if !f.record(Op{Kind: OpAppend, From: line, To: file}) {
+		return nil
+	}
+	out, err := os.OpenFile(file, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return xerrors.Errorf("error opening %v: %v", file, err)
+	}
+	_, err = fmt.Fprintln(out, line)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return xerrors.Errorf("error writing %v: %v", file, err)
+	}
+	return nil
+}
+
+// Summary is what a sync did to the files in the target, or would have done in a dry run
+type Summary struct {
+	Created   []string // links created in the target
+	BackedUp  []string // files in the target that were backed up, and replaced with links
+	Adopted   []string // files in the target that were moved into the source, and linked back
+	Ignored   []string // files that were added to the .envyignore
+	Skipped   []string // conflicts that were left alone
+	BackupDir string   // where the backed up files are
+}
+
+// printSummary prints the summary, and in a dry run, every change that would have been made
+func (f *fileOps) printSummary(s Summary) error {
+	w := &errWriter{w: f.out}
+	if f.dryRun {
+		w.printf("dry run, nothing was changed. The changes would be:\n")
+		for _, op := range f.ops {
+			w.printf("  %v\n", op)
+		}
+	}
+	sections := []struct {
+		title string
+		files []string
+	}{
+		{"created", s.Created},
+		{"backed up", s.BackedUp},
+		{"adopted", s.Adopted},
+		{"ignored", s.Ignored},
+		{"skipped", s.Skipped},
+	}
+	for _, section := range sections {
+		w.printf("%v: %d\n", section.title, len(section.files))
+		for _, file := range section.files {
+			w.printf("  %v\n", file)
+		}
+	}
+	if len(s.BackedUp) > 0 {
+		w.printf("the backups are in %v, and can be put back with `envy sync restore`\n", s.BackupDir)
+	}
+	return w.err
+}
+
+// errWriter keeps the first error of a series of writes
+type errWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (e *errWriter) printf(format string, args ...interface{}) {
+	if e.err == nil {
+		_, e.err = fmt.Fprintf(e.w, format, args...)
+	}
+}
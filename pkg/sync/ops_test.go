@@ -0,0 +1,93 @@
+package sync
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/morganhein/envy/pkg/io"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDryRunChangesNothing(t *testing.T) {
+	source, target := t.TempDir(), t.TempDir()
+	writeFiles(t, source, map[string]string{"a.conf": "source a", "nvim/init.lua": "source init"})
+	writeFiles(t, target, map[string]string{"a.conf": "target a", "b.conf": "target b"})
+	before := snapshot(t, source, target)
+
+	var out bytes.Buffer
+	term := &scriptedTerminal{Logger: io.NewLogger()}
+	config := SyncConfig{Source: source, Target: target, DryRun: true, term: term, ops: newFileOps(true, &out)}
+	backups := newBackupStore(t.TempDir(), target, config.ops, time.Now())
+	config.syncer = NewSyncer(io.NewFilesystem(), term, config.ops, backups, Adopt, "")
+
+	summary, err := syncHelper(context.Background(), config)
+	require.NoError(t, err)
+	assert.Equal(t, before, snapshot(t, source, target))
+	assert.Equal(t, []Op{
+		{Kind: OpMkdir, To: filepath.Join(backups.dir, source)},
+		{Kind: OpRename, From: filepath.Join(source, "a.conf"), To: filepath.Join(backups.dir, source, "a.conf")},
+		{Kind: OpRename, From: filepath.Join(target, "a.conf"), To: filepath.Join(source, "a.conf")},
+		{Kind: OpSymlink, From: filepath.Join(source, "a.conf"), To: filepath.Join(target, "a.conf")},
+		{Kind: OpMkdir, To: filepath.Join(target, "nvim")},
+		{Kind: OpSymlink, From: filepath.Join(source, "nvim/init.lua"), To: filepath.Join(target, "nvim/init.lua")},
+	}, config.ops.ops)
+
+	require.NoError(t, config.ops.printSummary(*summary))
+	assert.Contains(t, out.String(), "dry run, nothing was changed")
+	assert.Contains(t, out.String(), "created: 1\n  "+filepath.Join(target, "nvim/init.lua")+"\n")
+	assert.Contains(t, out.String(), "adopted: 1\n")
+}
+
+// snapshot lists every path under the directories, with the contents of files and the targets of links
+func snapshot(t *testing.T, dirs ...string) map[string]string {
+	files := map[string]string{}
+	for _, dir := range dirs {
+		err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			switch {
+			case info.Mode()&os.ModeSymlink != 0:
+				link, err := os.Readlink(path)
+				files[path] = "-> " + link
+				return err
+			case info.IsDir():
+				files[path] = "dir"
+			default:
+				body, err := os.ReadFile(path)
+				files[path] = string(body)
+				return err
+			}
+			return nil
+		})
+		require.NoError(t, err)
+	}
+	return files
+}
+
+func TestSyncCopyMode(t *testing.T) {
+	source, target := t.TempDir(), t.TempDir()
+	writeFiles(t, source, map[string]string{"a.conf": "source a", "b.conf": "source b", "c.conf": "source c"})
+	writeFiles(t, target, map[string]string{"b.conf": "source b", "c.conf": "target c"})
+	term := &scriptedTerminal{Logger: io.NewLogger()}
+	config := SyncConfig{Source: source, Target: target, Mode: CopyMode, term: term, ops: newFileOps(false, nil)}
+	backups := newBackupStore(t.TempDir(), target, config.ops, time.Now())
+	config.syncer = NewSyncer(io.NewFilesystem(), term, config.ops, backups, Adopt, CopyMode)
+
+	summary, err := syncHelper(context.Background(), config)
+	require.NoError(t, err)
+	// copies that are up to date are left alone
+	assert.Equal(t, []string{filepath.Join(target, "a.conf")}, summary.Created)
+	assert.Equal(t, []string{filepath.Join(target, "c.conf")}, summary.Adopted)
+	files := snapshot(t, target)
+	assert.Equal(t, "source a", files[filepath.Join(target, "a.conf")])
+	assert.Equal(t, "target c", files[filepath.Join(target, "c.conf")])
+	body, err := os.ReadFile(filepath.Join(source, "c.conf"))
+	assert.NoError(t, err)
+	assert.Equal(t, "target c", string(body))
+}
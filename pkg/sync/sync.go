@@ -2,14 +2,17 @@ package sync
 
 import (
 	"context"
-	"errors"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/AlecAivazis/survey/v2"
 	"github.com/karrick/godirwalk"
 	"github.com/morganhein/envy/pkg/io"
+	"golang.org/x/term"
 	"golang.org/x/xerrors"
 )
 
@@ -22,107 +25,276 @@ Terms:
 	$root_source is the same as $home_source but for / files
 	$root_target same as $home_target, but for / files
     $config_path is the full path and filename for the config file
-	$ignores are the list of files, fullpath or pattern, to ignore
+	$ignores are the patterns of files to ignore, in the gitignore format
 
 For every inner union of files in $home_source and $home_target, make sure it is a link from source to the target.
 	May have to resolve collisions
 For every outer left union of $home_source and $home_target, make a symlink from source to target
 For every outer right union of $home_source and $home_target, ask to move file to source and symlink or ignore (this means we need to keep state?)
 
-For now we won't into subfolders, instead the entire folder will just be moved and linked
+Directories are linked file by file, unless they are selected to be linked whole
 
 Folder structure in source will be .repo/
 										/home
 										/extra
 */
 
+// SyncMode is how the files of the source are put into the target
+type SyncMode string
+
+const (
+	LinkMode SyncMode = "link" // the target links to the files of the source
+	CopyMode SyncMode = "copy" // the target has copies of the files of the source
+)
+
+// ParseSyncMode parses the mode given on the command line or in the recipe, which defaults to linking
+func ParseSyncMode(mode string) (SyncMode, error) {
+	switch m := SyncMode(strings.ToLower(mode)); m {
+	case "":
+		return LinkMode, nil
+	case LinkMode, CopyMode:
+		return m, nil
+	}
+	return "", xerrors.Errorf("unknown sync mode `%v`, expected link or copy", mode)
+}
+
+// DirLinks selects the directories that are linked whole, instead of file by file
+type DirLinks struct {
+	All  bool            // link every directory whole
+	Dirs map[string]bool // overrides All for a directory, relative to the source and target
+}
+
+// any checks if any directory could be linked whole
+func (d DirLinks) any() bool {
+	if d.All {
+		return true
+	}
+	for _, link := range d.Dirs {
+		if link {
+			return true
+		}
+	}
+	return false
+}
+
 type SyncConfig struct {
-	Source  string
-	Target  string
-	DryRun  bool
-	Ignores []string
-	syncer  Syncer
-	term    io.Terminal
+	Source      string
+	Target      string
+	DryRun      bool
+	Dirs        []string       // the directories to sync, relative to the source and target. When empty, they are picked
+	AllDirs     bool           // when there are no Dirs, sync every directory of the source instead of picking them
+	Ignores     []string       // patterns in the gitignore format, relative to the source and target
+	Mode        SyncMode       // whether the target links to the source, or has copies
+	DirLinks    DirLinks       // the directories that are linked whole
+	Untracked   bool           // report the files that are only in the target, so they can be adopted
+	OnConflict  ConflictAction // OnConflict resolves every conflict the same way, instead of prompting
+	syncer      Syncer
+	term        io.Terminal
+	ops         *fileOps
+	interactive bool // if the directories can be picked, when there are none
 }
 
 func Sync(config SyncConfig) error {
-	config.syncer = NewSyncer(io.NewFilesystem())
+	if len(config.Source) == 0 {
+		return xerrors.New("there is no source to sync from, use --source or set the source of the [sync] section")
+	}
+	if len(config.Target) == 0 {
+		return xerrors.New("there is no target to sync into, use --target or set the target of the [sync] section")
+	}
+	if config.Mode == CopyMode && config.DirLinks.any() {
+		return xerrors.New("directories can only be linked whole in the link mode, not copied")
+	}
 	config.term = io.NewTerminal()
-	ctx := context.Background()
-	return syncHelper(ctx, config)
-}
-
-func syncHelper(ctx context.Context, config SyncConfig) error {
-	//if we don't already have an ignores (or a config isn't set, or something), then do a first pass to skip certain folders
-	dirs, err := config.syncer.GatherDirs(ctx, config.Target)
+	config.interactive = term.IsTerminal(int(os.Stdin.Fd()))
+	if len(config.OnConflict) == 0 && !config.interactive {
+		config.term.Warningf("not running in a terminal, so conflicts are skipped. Use --on-conflict to resolve them.")
+		config.OnConflict = Skip
+	}
+	config.ops = newFileOps(config.DryRun, os.Stdout)
+	backupDir, err := DefaultBackupDir()
 	if err != nil {
 		return err
 	}
-
-	selectedDirs := []string{}
-	//now ask which ones we want to keep
-	prompt := &survey.MultiSelect{
-		Message:  "Select which directories to keep in sync",
-		Options:  dirs,
-		PageSize: 15,
-	}
-	err = config.term.AskOne(prompt, &selectedDirs)
+	backups := newBackupStore(backupDir, config.Target, config.ops, time.Now())
+	config.syncer = NewSyncer(io.NewFilesystem(), config.term, config.ops, backups, config.OnConflict, config.Mode)
+	ctx := context.Background()
+	summary, err := syncHelper(ctx, config)
 	if err != nil {
 		return err
 	}
+	summary.BackupDir = backups.dir
+	return config.ops.printSummary(*summary)
+}
 
-	ignoredDirs := determineLeftOuterUnion(dirs, selectedDirs)
-	for i := 0; i < len(ignoredDirs); i++ {
-		ignoredDirs[i] = filepath.Join(config.Target, ignoredDirs[i])
+func syncHelper(ctx context.Context, config SyncConfig) (*Summary, error) {
+	dirs, err := selectDirs(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+	config.term.Infof("Starting, scanning base directories and %+v", dirs)
+	//the directories that aren't synced come first, then the defaults, which the config and .envyignore can re-include
+	ignoredFiles, err := loadIgnoreFile(config.Source)
+	if err != nil {
+		return nil, err
 	}
-	config.term.Infof("Starting, scanning base directories and %d directories", len(dirs)-len(ignoredDirs))
-	config.term.Infof("Ignoring %+v", ignoredDirs)
+	ignores := dirIgnores(dirs)
+	ignores = append(ignores, defaultIgnores...)
+	ignores = append(ignores, config.Ignores...)
+	ignores = append(ignores, ignoredFiles...)
 
-	mismatches, err := config.syncer.GatherMissingSymlinks(ctx, ignoredDirs, config.Source, config.Target)
+	mismatches, err := config.syncer.GatherMissingSymlinks(ctx, ignores, config.DirLinks, config.Untracked, config.Source, config.Target)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	config.term.Infof("%+v\n", mismatches)
+	return resolveMismatches(ctx, config, mismatches)
+}
 
+// resolveMismatches links the files missing from the target, and resolves the conflicts
+func resolveMismatches(ctx context.Context, config SyncConfig, mismatches []Mismatch) (*Summary, error) {
+	summary := &Summary{}
+	remaining := map[FileMismatchIssue]int{}
+	for _, f := range mismatches {
+		remaining[f.Issue]++
+	}
 	for _, f := range mismatches {
+		remaining[f.Issue]--
 		//if the mismatch is "missing from target", just symlink it
-		switch f.Issue {
-		case MissingFromTarget:
-			err = config.syncer.CreateSymlink(f.From, f.To, "backup")
-			if err != nil {
-				return xerrors.Errorf("error creating symlink: %v", err)
+		if f.Issue == MissingFromTarget || f.Issue == DirLinkedByFile {
+			if err := config.syncer.Place(f.From, f.To); err != nil {
+				return nil, err
 			}
-		case MissingFromSource:
-			//copy to source and symlink, which is done first since "backup" is defined
-			err = config.syncer.CreateSymlink(f.From, f.To, "backup")
-			if err != nil {
-				return xerrors.Errorf("error creating symlink: %v", err)
+			if f.Issue == DirLinkedByFile {
+				// the directory of links was backed up, so it can be restored
+				summary.BackedUp = append(summary.BackedUp, f.To)
+				continue
 			}
-		case FileCollision:
-			//decide what to do?
+			summary.Created = append(summary.Created, f.To)
+			continue
+		}
+		//otherwise there is a file in the target that the user has to decide about
+		action, err := config.syncer.ResolveFileConflict(ctx, f, remaining[f.Issue])
+		if err != nil {
+			return nil, err
+		}
+		if err = resolveHelper(config, f, action, summary); err != nil {
+			return nil, err
 		}
 	}
 
+	return summary, nil
+}
+
+// selectDirs returns the directories to sync. Without any in the config, they are picked from the directories of the
+// source and target when running in a terminal, and otherwise are the directories of the source.
+func selectDirs(ctx context.Context, config SyncConfig) ([]string, error) {
+	if len(config.Dirs) > 0 {
+		return config.Dirs, nil
+	}
+	sourceDirs, err := config.syncer.GatherDirs(ctx, config.Source)
+	if err != nil {
+		return nil, err
+	}
+	if !config.interactive || config.AllDirs {
+		return sourceDirs, nil
+	}
+	targetDirs, err := config.syncer.GatherDirs(ctx, config.Target)
+	if err != nil {
+		return nil, err
+	}
+	options := append(append([]string{}, sourceDirs...), determineLeftOuterUnion(targetDirs, sourceDirs)...)
+	sort.Strings(options)
+	selectedDirs := []string{}
+	prompt := &survey.MultiSelect{
+		Message:  "Select which directories to keep in sync",
+		Options:  options,
+		Default:  sourceDirs,
+		PageSize: 15,
+	}
+	if err = config.term.AskOne(prompt, &selectedDirs); err != nil {
+		return nil, err
+	}
+	config.term.Infof("to sync these directories without asking, set `dirs = [\"%v\"]` in the [sync] section of the recipe",
+		strings.Join(selectedDirs, "\", \""))
+	return selectedDirs, nil
+}
+
+// resolveHelper performs the action the user chose for a file in the target
+func resolveHelper(config SyncConfig, f Mismatch, action ConflictAction, summary *Summary) error {
+	switch action {
+	case BackupAndLink:
+		if err := config.syncer.Place(f.From, f.To); err != nil {
+			return err
+		}
+		summary.BackedUp = append(summary.BackedUp, f.To)
+	case Adopt:
+		if err := config.syncer.Adopt(f.From, f.To); err != nil {
+			return err
+		}
+		summary.Adopted = append(summary.Adopted, f.To)
+	case Ignore:
+		relative, err := filepath.Rel(config.Source, f.From)
+		if err != nil {
+			return err
+		}
+		if err = config.syncer.IgnorePermanently(config.Source, relative); err != nil {
+			return err
+		}
+		summary.Ignored = append(summary.Ignored, f.To)
+	case Skip:
+		config.term.Infof("skipping %v", f.To)
+		summary.Skipped = append(summary.Skipped, f.To)
+	default:
+		return xerrors.Errorf("unknown conflict action `%v`", action)
+	}
 	return nil
 }
 
 type Syncer interface {
 	GatherDirs(ctx context.Context, target string) ([]string, error)
-	GatherMissingSymlinks(ctx context.Context, ignoredDirs []string, source, target string) ([]Mismatch, error)
-	CreateSymlink(from, to, backup string) error
+	// GatherMissingSymlinks finds the mismatches, skipping the paths matching the ignores, in the gitignore format.
+	// The directories selected by links are compared as a whole. The files that are only in the target are only
+	// reported when untracked is set.
+	GatherMissingSymlinks(ctx context.Context, ignores []string, links DirLinks, untracked bool, source, target string) ([]Mismatch, error)
+	// Place links from into to, or copies it in copy mode. A file already at to is backed up first.
+	Place(from, to string) error
+	ResolveFileConflict(ctx context.Context, m Mismatch, remaining int) (ConflictAction, error)
+	Adopt(from, to string) error
+	IgnorePermanently(source, relative string) error
 }
 
-func NewSyncer(fs io.Filesystem) *syncer {
-	return &syncer{fs: fs}
+func NewSyncer(fs io.Filesystem, term io.Terminal, ops *fileOps, backups *backupStore, policy ConflictAction, mode SyncMode) *syncer {
+	if len(mode) == 0 {
+		mode = LinkMode
+	}
+	return &syncer{
+		fs:      fs,
+		term:    term,
+		ops:     ops,
+		backups: backups,
+		policy:  policy,
+		mode:    mode,
+		repeat:  map[FileMismatchIssue]ConflictAction{},
+	}
 }
 
 type syncer struct {
-	fs io.Filesystem
+	fs      io.Filesystem
+	term    io.Terminal
+	ops     *fileOps // every change to the filesystem goes through ops, so dry runs change nothing
+	backups *backupStore
+	policy  ConflictAction                       // used for every conflict, instead of prompting
+	mode    SyncMode                             // whether the files are linked or copied into the target
+	repeat  map[FileMismatchIssue]ConflictAction // the actions the user chose to repeat for the remaining files
 }
 
-func (s syncer) CreateSymlink(from, to, backup string) error {
-	//detect if file is symlink to correct location already
+func (s syncer) Place(from, to string) error {
+	//detect if the target is already the source
 	alreadyGood := func() bool {
+		if s.mode == CopyMode {
+			same, err := sameContents(from, to)
+			return err == nil && same
+		}
 		stat, err := os.Lstat(to)
 		if err != nil {
 			return false
@@ -130,7 +302,7 @@ func (s syncer) CreateSymlink(from, to, backup string) error {
 		if !(stat.Mode()&os.ModeSymlink != 0) {
 			return false
 		}
-		ogFile, err := os.Readlink(stat.Name())
+		ogFile, err := os.Readlink(to)
 		return from == ogFile
 	}()
 
@@ -138,16 +310,19 @@ func (s syncer) CreateSymlink(from, to, backup string) error {
 		return nil
 	}
 
-	//try moving the file, ignore fileNotFound error
-	err := os.Rename(to, backup)
-	if err != nil && !errors.Is(err, os.ErrNotExist) {
-		return err
+	//move the file out of the way, if there is one
+	if _, err := os.Lstat(to); err == nil {
+		if _, err = s.backups.Backup(to, from, s.mode); err != nil {
+			return err
+		}
 	}
 
+	if s.mode == CopyMode {
+		return s.ops.Copy(from, to)
+	}
 	//make new symlink
-	err = os.Symlink(from, to)
-	if err != nil {
-		return xerrors.Errorf("error symlinking file: %v", err)
+	if err := s.ops.Symlink(from, to); err != nil {
+		return xerrors.Errorf("error creating symlink: %v", err)
 	}
 	return nil
 }
@@ -167,7 +342,15 @@ func (s syncer) GatherDirs(ctx context.Context, target string) ([]string, error)
 }
 
 // GatherMissingSymlinks looks and for all the files missing, and creates a collection of mismatched files
-func (s syncer) GatherMissingSymlinks(ctx context.Context, ignores []string, source, target string) ([]Mismatch, error) {
+func (s syncer) GatherMissingSymlinks(ctx context.Context, ignores []string, links DirLinks, untracked bool, source, target string) ([]Mismatch, error) {
+	matcher, err := compileIgnores(ignores)
+	if err != nil {
+		return nil, err
+	}
+	dirLinks := map[string]bool{}
+	for dir, link := range links.Dirs {
+		dirLinks[strings.Trim(filepath.ToSlash(filepath.Clean(dir)), "/")] = link
+	}
 	issues := make([]Mismatch, 0)
 	w := walker{
 		fs:         s.fs,
@@ -175,10 +358,14 @@ func (s syncer) GatherMissingSymlinks(ctx context.Context, ignores []string, sou
 		baseTarget: target,
 		issues:     issues,
 		ignores:    ignores,
+		matcher:    matcher,
+		mode:       s.mode,
+		linkDirs:   links.All,
+		dirLinks:   dirLinks,
 		log:        io.NewLogger(), //TODO (@morgan): this logger should be injected
 	}
 
-	err := godirwalk.Walk(source, &godirwalk.Options{
+	err = godirwalk.Walk(source, &godirwalk.Options{
 		Callback: w.GoWalkerSourceToTarget,
 		ErrorCallback: func(s string, err error) godirwalk.ErrorAction {
 			return godirwalk.SkipNode
@@ -188,22 +375,19 @@ func (s syncer) GatherMissingSymlinks(ctx context.Context, ignores []string, sou
 	if err != nil {
 		return nil, err
 	}
+	//the target, such as $HOME, is full of files that have nothing to do with the source
+	if !untracked {
+		return w.issues, nil
+	}
 
-	w.baseSource = target
-	w.baseTarget = source
 	err = godirwalk.Walk(target, &godirwalk.Options{
-		Callback: w.GoWalkerTargetToSource},
-	)
+		Callback: w.GoWalkerTargetToSource,
+		ErrorCallback: func(s string, err error) godirwalk.ErrorAction {
+			return godirwalk.SkipNode
+		},
+	})
 	if err != nil {
 		return nil, err
 	}
 	return w.issues, nil
 }
-
-// Prompts the user on what course of action to perform on a file conflict:
-// 1. Rename target and move to a backup, symlink source to target
-// 2. Ignore file and ignore symlink from now on.
-// 3. Append file contents, move file, and symlink?
-func (s syncer) ResolveFileConflict(ctx context.Context, from, to string) error {
-	return nil
-}
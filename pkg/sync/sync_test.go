@@ -13,39 +13,40 @@ import (
 func TestGoWalkerSourceToTarget(t *testing.T) {
 	w := &walker{
 		fs:         io.NewFilesystem(),
-		baseSource: "../tests/sync_tests_folders/source",
-		baseTarget: "../tests/sync_tests_folders/target",
+		baseSource: "../../test/sync_tests_folders/source",
+		baseTarget: "../../test/sync_tests_folders/target",
 		issues:     []Mismatch{},
 		ignores:    []string{".3T", ".Trash", ".azure", ".cache", ".cups", ".dlv", ".docker", ".eclipse", ".gvm", ".iterm2", ".kube", ".local", ".matrix", ".node-gyp", ".npm", ".oh-my-zsh", ".pgadmin", ".ssh", ".tabnine", ".tldrc", ".vnc", ".vscode", "Applications", "Desktop", "Documents", "Downloads", "Library", "Movies", "Music", "OneDrive", "Pictures", "Projects", "Public", "athens-storage", "dump", "go", "tmp"},
 		log:        io.NewLogger(),
 	}
 
-	err := godirwalk.Walk("../tests/sync_tests_folders/source", &godirwalk.Options{
+	err := godirwalk.Walk("../../test/sync_tests_folders/source", &godirwalk.Options{
 		Callback: w.GoWalkerSourceToTarget},
 	)
 	assert.NoError(t, err)
 	assert.NotEmpty(t, w.issues)
-	assert.Len(t, w.issues, 2)
+	assert.Len(t, w.issues, 3)
 	assert.Equal(t, w.issues[0].Issue, FileCollision)
-	assert.Equal(t, w.issues[1].Issue, MissingFromTarget)
+	assert.Equal(t, w.issues[1].Issue, FileCollision)
+	assert.Equal(t, w.issues[2].Issue, MissingFromTarget)
 }
 
 func TestGoWalkerTargetToSource(t *testing.T) {
 	w := &walker{
 		fs:         io.NewFilesystem(),
-		baseSource: "../tests/sync_tests_folders/source",
-		baseTarget: "../tests/sync_tests_folders/target",
+		baseSource: "../../test/sync_tests_folders/source",
+		baseTarget: "../../test/sync_tests_folders/target",
 		issues:     []Mismatch{},
 	}
 
-	err := godirwalk.Walk("../tests/sync_tests_folders/target", &godirwalk.Options{
+	err := godirwalk.Walk("../../test/sync_tests_folders/target", &godirwalk.Options{
 		Callback: w.GoWalkerTargetToSource},
 	)
 	assert.NoError(t, err)
 	assert.NotEmpty(t, w.issues)
-	assert.Len(t, w.issues, 2)
-	assert.Equal(t, w.issues[0].Issue, FileCollision)
-	assert.Equal(t, w.issues[1].Issue, MissingFromSource)
+	// files in both are checked when walking the source
+	assert.Len(t, w.issues, 1)
+	assert.Equal(t, w.issues[0].Issue, MissingFromSource)
 }
 
 func TestOnDisk_TEMPORARY(t *testing.T) {
@@ -1,11 +1,11 @@
 package sync
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"os"
 	"path/filepath"
-	"strings"
 
 	"github.com/karrick/godirwalk"
 	"github.com/morganhein/envy/pkg/io"
@@ -24,8 +24,15 @@ const (
 	MissingFromTarget FileMismatchIssue = "missing from target"
 	MissingFromSource FileMismatchIssue = "missing from source"
 	FileCollision     FileMismatchIssue = "file collision"
+	// DirCollision is a directory to link whole, where the target has a directory with files of its own
+	DirCollision FileMismatchIssue = "directory collision"
+	// DirLinkedByFile is a directory to link whole, where the target only has links to the files in it, so it's
+	// replaced without asking
+	DirLinkedByFile FileMismatchIssue = "directory linked file by file"
 )
 
+// Mismatch is a file that isn't linked from the source into the target. From is always the path in the source,
+// and To the path in the target.
 type Mismatch struct {
 	From  string
 	To    string
@@ -38,53 +45,100 @@ type walker struct {
 	baseSource string // always the $home_source or $root_source
 	baseTarget string // always the $home_target or $root_target
 	issues     []Mismatch
-	ignores    []string // list of filenames or regular expressions to ignore, to be added
+	ignores    []string // patterns in the gitignore format, matched against paths relative to the base
+	matcher    *ignoreMatcher
 	log        io.Logger
-	linkDirs   bool // if enabled, don't link individual files, symlink entire directories
+	mode       SyncMode
+	linkDirs   bool            // if enabled, don't link individual files, symlink entire directories
+	dirLinks   map[string]bool // overrides linkDirs for a directory, relative to the base
 }
 
-func (w *walker) isIgnored(pathname string) bool {
-	for _, v := range w.ignores {
-		if pathname == v {
-			return true
+// errStopWalk ends a walk early, once the answer is known
+var errStopWalk = errors.New("stop walking")
+
+// linkWhole checks if the directory, relative to the base, is linked as a whole instead of file by file. A
+// directory with anything ignored inside it can't be, since the ignored files would be linked along with it.
+func (w *walker) linkWhole(base, pathName string) bool {
+	if w.mode == CopyMode {
+		return false
+	}
+	relativePath, err := filepath.Rel(base, pathName)
+	if err != nil {
+		return false
+	}
+	link, ok := w.dirLinks[filepath.ToSlash(relativePath)]
+	if !ok {
+		link = w.linkDirs
+	}
+	if !link {
+		return false
+	}
+	ignored := false
+	_ = filepath.WalkDir(pathName, func(p string, d os.DirEntry, err error) error {
+		if err != nil || p == pathName {
+			return err
+		}
+		if w.isIgnored(base, p, d.IsDir()) {
+			ignored = true
+			return errStopWalk
 		}
+		return nil
+	})
+	if ignored {
+		w.log.Debugf("linking the files of %v one by one, since some of them are ignored", pathName)
 	}
-	return false
+	return !ignored
 }
 
+// isIgnored checks the path, relative to the base it is in, against the ignore patterns
+func (w *walker) isIgnored(base, pathname string, isDir bool) bool {
+	if w.matcher == nil {
+		// invalid patterns are reported when the walk starts, so the valid ones are enough here
+		w.matcher, _ = compileIgnores(w.ignores)
+	}
+	relativePath, err := filepath.Rel(base, pathname)
+	if err != nil {
+		return false
+	}
+	return w.matcher.Match(relativePath, isDir)
+}
+
+// GoWalkerSourceToTarget walks the source, and finds the files that are missing from the target or collide with it
 func (w *walker) GoWalkerSourceToTarget(pathName string, dir *godirwalk.Dirent) error {
-	//keep things sane and scan the correct folders
+	//the walk starts at the source itself
 	if filepath.Clean(pathName) == filepath.Clean(w.baseSource) {
-		return godirwalk.SkipThis
-	}
-	if filepath.Clean(pathName) == filepath.Clean(w.baseTarget) {
 		return nil
 	}
-	if w.isIgnored(pathName) {
+	if w.isIgnored(w.baseSource, pathName, dir.IsDir()) {
 		w.log.Debugf("skipping %v", pathName)
 		return godirwalk.SkipThis
 	}
-	if dir.IsDir() && !w.linkDirs {
+	if !dir.IsDir() {
+		return w.sourceToTargetHelper(pathName)
+	}
+	if !w.linkWhole(w.baseSource, pathName) {
 		return nil
 	}
-	if !dir.IsDir() && w.linkDirs {
-		return godirwalk.SkipThis
+	if err := w.sourceToTargetHelper(pathName); err != nil {
+		return err
 	}
-
-	return w.sourceToTargetHelper(pathName)
+	return godirwalk.SkipThis
 }
 
 func (w *walker) sourceToTargetHelper(pathName string) error {
 	//get relative path
-	relativePath := strings.TrimPrefix(pathName, w.baseTarget)
-	sourcePath := filepath.Join(w.baseSource, relativePath)
+	relativePath, err := filepath.Rel(w.baseSource, pathName)
+	if err != nil {
+		return err
+	}
+	targetPath := filepath.Join(w.baseTarget, relativePath)
 	//check if this file also exists in target
-	_, err := w.fs.Stat(sourcePath)
+	_, err = w.fs.Stat(targetPath)
 	if err != nil && errors.Is(err, os.ErrNotExist) {
 		// path/to/whatever does not exist, symlink and return
 		w.issues = append(w.issues, Mismatch{
 			From:  pathName,
-			To:    sourcePath,
+			To:    targetPath,
 			Issue: MissingFromTarget,
 		})
 		return nil
@@ -93,71 +147,140 @@ func (w *walker) sourceToTargetHelper(pathName string) error {
 		return err
 	}
 
-	//check if path is already symlinking to sourcePath
-	alreadyLinked, err := w.fs.IsSymlinkTo(pathName, sourcePath)
-	if err != nil {
-		return err
+	if w.mode == CopyMode {
+		//check if the target is already a copy of the source
+		same, err := sameContents(pathName, targetPath)
+		if err == nil && same {
+			return nil
+		}
+	} else {
+		//check if the target is already symlinking to the source. A link that can't be resolved is not.
+		alreadyLinked, err := w.fs.IsSymlinkTo(targetPath, pathName)
+		if err == nil && alreadyLinked {
+			return nil
+		}
 	}
-	if alreadyLinked {
+
+	//a directory that was linked file by file can be replaced, but one with files of its own has to be resolved
+	if info, err := os.Stat(pathName); err == nil && info.IsDir() {
+		issue := DirCollision
+		if linkedFiles(pathName, targetPath) {
+			issue = DirLinkedByFile
+		}
+		w.issues = append(w.issues, Mismatch{From: pathName, To: targetPath, Issue: issue})
 		return nil
 	}
 
 	//a match exists, but is not a symlink to the correct location
 	w.issues = append(w.issues, Mismatch{
 		From:  pathName,
-		To:    sourcePath,
+		To:    targetPath,
 		Issue: FileCollision,
 	})
 	return nil
 }
 
+// GoWalkerTargetToSource walks the target, and finds the files that are missing from the source. Files that
+// exist in both are checked while walking the source.
 func (w *walker) GoWalkerTargetToSource(pathName string, dir *godirwalk.Dirent) error {
-	//skip this if this is the source repo
-	if pathName == w.baseSource {
+	//the walk starts at the target itself
+	if filepath.Clean(pathName) == filepath.Clean(w.baseTarget) {
+		return nil
+	}
+	//skip this if this is the source repo, which is often kept in the target
+	if filepath.Clean(pathName) == filepath.Clean(w.baseSource) {
 		return godirwalk.SkipThis
 	}
-	if w.isIgnored(pathName) {
+	if w.isIgnored(w.baseTarget, pathName, dir.IsDir()) {
 		return godirwalk.SkipThis
 	}
 	if dir.IsDir() {
-		if w.linkDirs {
-			//if it's a di
-			return godirwalk.SkipThis
+		relativePath, err := filepath.Rel(w.baseTarget, pathName)
+		if err != nil {
+			return err
+		}
+		sourcePath := filepath.Join(w.baseSource, relativePath)
+		if _, err = w.fs.Stat(sourcePath); err == nil {
+			//a directory the source also has is checked while walking the source, when it's linked whole
+			if w.linkWhole(w.baseSource, sourcePath) {
+				return godirwalk.SkipThis
+			}
+			return nil
+		}
+		if !w.linkWhole(w.baseTarget, pathName) {
+			return nil
 		}
+		w.issues = append(w.issues, Mismatch{From: sourcePath, To: pathName, Issue: MissingFromSource})
+		return godirwalk.SkipThis
+	}
+	//links are not files to adopt
+	if dir.IsSymlink() {
 		return nil
 	}
 	//get relative path
-	relativePath := strings.TrimPrefix(pathName, w.baseTarget)
+	relativePath, err := filepath.Rel(w.baseTarget, pathName)
+	if err != nil {
+		return err
+	}
 	sourcePath := filepath.Join(w.baseSource, relativePath)
-	//check if this file also exists in target
-	_, err := w.fs.Stat(sourcePath)
+	//check if this file also exists in source
+	_, err = w.fs.Stat(sourcePath)
 	if err != nil && errors.Is(err, os.ErrNotExist) {
-		// path/to/whatever does not exist, symlink and return
 		w.issues = append(w.issues, Mismatch{
-			From:  pathName,
-			To:    sourcePath,
+			From:  sourcePath,
+			To:    pathName,
 			Issue: MissingFromSource,
 		})
 		return nil
 	}
+	return err
+}
+
+// sameContents checks if the target is a regular file with the same contents and mode as the source
+func sameContents(source, target string) (bool, error) {
+	sourceInfo, err := os.Stat(source)
 	if err != nil {
-		return err
+		return false, err
 	}
-
-	//check if path is already symlinking to sourcePath
-	alreadyLinked, err := w.fs.IsSymlinkTo(pathName, sourcePath)
+	targetInfo, err := os.Lstat(target)
 	if err != nil {
-		return err
+		return false, err
 	}
-	if alreadyLinked {
-		return nil
+	if !targetInfo.Mode().IsRegular() || sourceInfo.Mode() != targetInfo.Mode() || sourceInfo.Size() != targetInfo.Size() {
+		return false, nil
+	}
+	a, err := os.ReadFile(source)
+	if err != nil {
+		return false, err
 	}
+	b, err := os.ReadFile(target)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(a, b), nil
+}
 
-	//a match exists, but the symlink is to the wrong location
-	w.issues = append(w.issues, Mismatch{
-		From:  pathName,
-		To:    sourcePath,
-		Issue: FileCollision,
+// linkedFiles checks if every file in the target directory is a link to the same file in the source directory
+func linkedFiles(source, target string) bool {
+	linked := true
+	_ = filepath.WalkDir(target, func(p string, d os.DirEntry, err error) error {
+		if err != nil {
+			linked = false
+			return errStopWalk
+		}
+		if d.IsDir() {
+			return nil
+		}
+		relativePath, err := filepath.Rel(target, p)
+		if err != nil {
+			return err
+		}
+		link, err := os.Readlink(p)
+		if err != nil || filepath.Clean(link) != filepath.Join(source, relativePath) {
+			linked = false
+			return errStopWalk
+		}
+		return nil
 	})
-	return nil
+	return linked
 }